@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+
+	"go.uber.org/zap"
+
+	"tweetdeleter/internal"
+)
+
+// runBluesky deletes the posts and reposts a Bluesky account made in a time range, and optionally its
+// likes. It returns the process exit code.
+func runBluesky(logger *zap.Logger, args []string) int {
+	fs := flag.NewFlagSet("tweetdeleter bluesky", flag.ExitOnError)
+	handle := fs.String("handle", "", "bluesky handle, such as alice.bsky.social, of the account to delete posts from")
+	appPassword := fs.String("app-password", "", "app password created in bluesky's settings")
+	pdsURL := fs.String("pds", "", "personal data server hosting the account, such as a local fake pds. https://bsky.social is used if empty")
+	startDate := fs.String("start-date", "", "start date of time range to delete posts. must be formatted as YYYY-MM-DD")
+	endDate := fs.String("end-date", "", "end date of time range to delete posts. must be formatted as YYYY-MM-DD")
+	likes := fs.Bool("likes", false, "also remove likes made in the time range")
+	_ = fs.Parse(args)
+
+	if *handle == "" {
+		logger.Fatal("handle flag is required")
+	}
+	if *appPassword == "" {
+		logger.Fatal("app-password flag is required")
+	}
+	parsedStart, parsedEnd := parseDateRange(logger, *startDate, *endDate)
+
+	bd, err := internal.NewBlueskyDeleter(internal.BlueskyOptions{
+		Handle:      *handle,
+		AppPassword: *appPassword,
+		StartDate:   parsedStart,
+		EndDate:     parsedEnd,
+		Logger:      logger,
+		PDSURL:      *pdsURL,
+		Likes:       *likes,
+	})
+	if err != nil {
+		logger.Fatal("could not create BlueskyDeleter", zap.Error(err))
+	}
+	if err := bd.Run(); err != nil {
+		logger.Error("error running BlueskyDeleter", zap.Error(err))
+		return exitCode(err)
+	}
+	return exitOK
+}
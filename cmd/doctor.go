@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/zap"
+
+	"tweetdeleter/internal"
+)
+
+// runDoctor checks that chrome, the selector profile and the saved session are usable and prints
+// a table of the results. It returns the process exit code, which is non-zero if any check failed.
+func runDoctor(logger *zap.Logger, args []string) int {
+	fs := flag.NewFlagSet("tweetdeleter doctor", flag.ExitOnError)
+	browser := addBrowserFlags(fs)
+	searchSince := fs.String("search-since", "", "optional start of a time range known to contain tweets, used to check searching and the tweet menu without deleting anything. must be formatted as YYYY-MM-DD")
+	searchUntil := fs.String("search-until", "", "end of the time range provided by search-since. must be formatted as YYYY-MM-DD")
+	_ = fs.Parse(args)
+
+	var opts internal.DoctorOptions
+	if *searchSince != "" || *searchUntil != "" {
+		var err error
+		if opts.SearchSince, err = time.Parse(time.DateOnly, *searchSince); err != nil {
+			logger.Fatal("could not parse search-since", zap.Error(err))
+		}
+		if opts.SearchUntil, err = time.Parse(time.DateOnly, *searchUntil); err != nil {
+			logger.Fatal("could not parse search-until", zap.Error(err))
+		}
+	}
+
+	td, err := internal.NewTweetDeleter(browser.options(logger))
+	if err != nil {
+		logger.Fatal("could not create TweetDeleter", zap.Error(err))
+	}
+	return printResults(td.Doctor(opts))
+}
+
+// printResults prints a table of check results and returns the process exit code, which is non-zero
+// if any check failed
+func printResults(results []internal.CheckResult) int {
+	code := exitOK
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tSTATUS\tDETAIL")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.Name, r.Status, r.Detail)
+		if r.Status == internal.CheckFailed {
+			code = exitError
+		}
+	}
+	_ = w.Flush()
+	return code
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+
+	"tweetdeleter/internal"
+)
+
+// Process exit codes. These let schedulers and alerting tell apart failures that need a human,
+// like a bad password, from ones that need a code change, like X changing its UI.
+const (
+	exitOK                = 0
+	exitError             = 1
+	exitLoginFailed       = 3
+	exitChallengeRequired = 4
+	exitAccountLocked     = 5
+	exitRateLimited       = 6
+	exitSelectorMissing   = 7
+	exitPartialCompletion = 8
+)
+
+// exitCode maps an error returned by TweetDeleter to a process exit code. The underlying cause
+// takes priority, so exitPartialCompletion is only used when the cause is not otherwise known.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, internal.ErrAccountLocked):
+		return exitAccountLocked
+	case errors.Is(err, internal.ErrChallengeRequired):
+		return exitChallengeRequired
+	case errors.Is(err, internal.ErrLoginFailed):
+		return exitLoginFailed
+	case errors.Is(err, internal.ErrRateLimited):
+		return exitRateLimited
+	case errors.Is(err, internal.ErrSelectorMissing):
+		return exitSelectorMissing
+	case errors.Is(err, internal.ErrPartialCompletion):
+		return exitPartialCompletion
+	default:
+		return exitError
+	}
+}
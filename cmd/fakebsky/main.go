@@ -0,0 +1,60 @@
+// Command fakebsky serves an offline stand-in for a Bluesky PDS that tweetdeleter bluesky can be
+// pointed at with -pds
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"tweetdeleter/internal/fakebsky"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8090", "address to serve the fake pds on")
+	handle := flag.String("handle", "fake.bsky.social", "handle of the fake account")
+	appPassword := flag.String("app-password", "fake", "app password of the fake account")
+	recordsFile := flag.String("records", "", "path to a JSON file containing the account's records. generated records are used if empty")
+	count := flag.Int("count", 25, "number of posts to generate when no records file is provided. a fifth as many reposts and likes are generated too")
+	startDate := flag.String("start-date", "2023-01-01", "start date of generated records. must be formatted as YYYY-MM-DD")
+	endDate := flag.String("end-date", "2023-03-01", "end date of generated records. must be formatted as YYYY-MM-DD")
+	accessLifetime := flag.Duration("access-lifetime", 2*time.Hour, "how long access tokens issued by the fake pds are valid")
+	rateLimit := flag.Int("rate-limit", 0, "throttle every n+1th record deletion with a 429. no deletions are throttled if 0")
+	flag.Parse()
+
+	var records []fakebsky.Record
+	if *recordsFile != "" {
+		data, err := os.ReadFile(*recordsFile)
+		if err != nil {
+			log.Fatalf("could not read records: %v", err)
+		}
+		if err := json.Unmarshal(data, &records); err != nil {
+			log.Fatalf("could not parse records: %v", err)
+		}
+	} else {
+		since, err := time.Parse(time.DateOnly, *startDate)
+		if err != nil {
+			log.Fatalf("could not parse start date: %v", err)
+		}
+		until, err := time.Parse(time.DateOnly, *endDate)
+		if err != nil {
+			log.Fatalf("could not parse end date: %v", err)
+		}
+		records = fakebsky.GenerateRecords(fakebsky.PostCollection, *count, since, until)
+		records = append(records, fakebsky.GenerateRecords(fakebsky.RepostCollection, *count/5, since, until)...)
+		records = append(records, fakebsky.GenerateRecords(fakebsky.LikeCollection, *count/5, since, until)...)
+	}
+
+	pds := fakebsky.NewPDS(fakebsky.Options{
+		Handle:         *handle,
+		AppPassword:    *appPassword,
+		Records:        records,
+		AccessLifetime: *accessLifetime,
+		RateLimit:      *rateLimit,
+	})
+	log.Printf("serving %d records for %s on http://%s", len(records), *handle, *addr)
+	log.Fatal(http.ListenAndServe(*addr, pds))
+}
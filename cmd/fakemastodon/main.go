@@ -0,0 +1,58 @@
+// Command fakemastodon serves an offline stand-in for a Mastodon server that tweetdeleter mastodon can
+// be pointed at with -server
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"tweetdeleter/internal/fakemastodon"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8100", "address to serve the fake mastodon server on")
+	username := flag.String("username", "fake", "username of the fake account")
+	accessToken := flag.String("access-token", "fake", "access token of the fake account")
+	statusesFile := flag.String("statuses", "", "path to a JSON file containing the account's statuses. generated statuses are used if empty")
+	count := flag.Int("count", 25, "number of statuses to generate when no statuses file is provided. a fifth as many favourites are generated too")
+	startDate := flag.String("start-date", "2023-01-01", "start date of generated statuses. must be formatted as YYYY-MM-DD")
+	endDate := flag.String("end-date", "2023-03-01", "end date of generated statuses. must be formatted as YYYY-MM-DD")
+	rateLimit := flag.Int("rate-limit", 0, "throttle every n+1th status deletion with a 429. no deletions are throttled if 0")
+	flag.Parse()
+
+	var statuses, favourites []fakemastodon.Status
+	if *statusesFile != "" {
+		data, err := os.ReadFile(*statusesFile)
+		if err != nil {
+			log.Fatalf("could not read statuses: %v", err)
+		}
+		if err := json.Unmarshal(data, &statuses); err != nil {
+			log.Fatalf("could not parse statuses: %v", err)
+		}
+	} else {
+		since, err := time.Parse(time.DateOnly, *startDate)
+		if err != nil {
+			log.Fatalf("could not parse start date: %v", err)
+		}
+		until, err := time.Parse(time.DateOnly, *endDate)
+		if err != nil {
+			log.Fatalf("could not parse end date: %v", err)
+		}
+		statuses = fakemastodon.GenerateStatuses(*count, since, until)
+		favourites = fakemastodon.GenerateStatuses(*count/5, since, until)
+	}
+
+	instance := fakemastodon.NewInstance(fakemastodon.Options{
+		Username:    *username,
+		AccessToken: *accessToken,
+		Statuses:    statuses,
+		Favourites:  favourites,
+		RateLimit:   *rateLimit,
+	})
+	log.Printf("serving %d statuses for %s on http://%s", len(statuses), *username, *addr)
+	log.Fatal(http.ListenAndServe(*addr, instance))
+}
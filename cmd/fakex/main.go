@@ -0,0 +1,79 @@
+// Command fakex serves an offline stand-in for X that tweetdeleter can be pointed at with -base-url,
+// and optionally a fake of the X API that it can be pointed at with -api-base-url
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"tweetdeleter/internal/fakex"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8080", "address to serve the fake site on")
+	username := flag.String("username", "fake", "username of the fake account")
+	password := flag.String("password", "fake", "password of the fake account")
+	tweetsFile := flag.String("tweets", "", "path to a JSON file containing the account's tweets. generated tweets are used if empty")
+	count := flag.Int("count", 25, "number of tweets to generate when no tweets file is provided")
+	startDate := flag.String("start-date", "2023-01-01", "start date of generated tweets. must be formatted as YYYY-MM-DD")
+	endDate := flag.String("end-date", "2023-03-01", "end date of generated tweets. must be formatted as YYYY-MM-DD")
+	challenge := flag.Bool("challenge", false, "ask for a verification code instead of logging in")
+	lang := flag.String("lang", "en", "language of the site's UI")
+	searchLag := flag.Bool("search-lag", false, "keep showing deleted tweets in the results of the next search")
+	logOutAfter := flag.Int("log-out-after", 0, "log every session out once this many tweets have been deleted. sessions aren't logged out if 0")
+	logOutAfterPosts := flag.Int("log-out-after-posts", 0, "log every session out as soon as this many tweets have been posted. sessions aren't logged out by posting if 0")
+	apiAddr := flag.String("api-addr", "", "address to serve a fake x api for the same account on. the api isn't served if empty")
+	tokenLifetime := flag.Duration("token-lifetime", 2*time.Hour, "how long access tokens issued by the fake api are valid")
+	archive := flag.String("archive", "", "directory to write an x data archive of the account's tweets to, for -migrate-archive or restore. no archive is written if empty")
+	flag.Parse()
+
+	var tweets []fakex.Tweet
+	if *tweetsFile != "" {
+		data, err := os.ReadFile(*tweetsFile)
+		if err != nil {
+			log.Fatalf("could not read tweets: %v", err)
+		}
+		if err := json.Unmarshal(data, &tweets); err != nil {
+			log.Fatalf("could not parse tweets: %v", err)
+		}
+	} else {
+		since, err := time.Parse(time.DateOnly, *startDate)
+		if err != nil {
+			log.Fatalf("could not parse start date: %v", err)
+		}
+		until, err := time.Parse(time.DateOnly, *endDate)
+		if err != nil {
+			log.Fatalf("could not parse end date: %v", err)
+		}
+		tweets = fakex.GenerateTweets(*count, since, until)
+	}
+
+	if *archive != "" {
+		if err := fakex.WriteArchive(*archive, tweets, nil); err != nil {
+			log.Fatalf("could not write archive: %v", err)
+		}
+		log.Printf("wrote an archive of %d tweets to %s", len(tweets), *archive)
+	}
+
+	site := fakex.NewSite(fakex.Options{
+		Username:         *username,
+		Password:         *password,
+		Tweets:           tweets,
+		Challenge:        *challenge,
+		Lang:             *lang,
+		SearchLag:        *searchLag,
+		LogOutAfter:      *logOutAfter,
+		LogOutAfterPosts: *logOutAfterPosts,
+	})
+	if *apiAddr != "" {
+		api := fakex.NewAPI(site, fakex.APIOptions{TokenLifetime: *tokenLifetime})
+		log.Printf("serving the fake x api on http://%s", *apiAddr)
+		go func() { log.Fatal(http.ListenAndServe(*apiAddr, api)) }()
+	}
+	log.Printf("serving %d tweets for %s on http://%s", len(tweets), *username, *addr)
+	log.Fatal(http.ListenAndServe(*addr, site))
+}
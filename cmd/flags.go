@@ -0,0 +1,188 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"go.uber.org/zap"
+
+	"tweetdeleter/internal"
+)
+
+// browserFlags are the flags shared by every command that drives chrome
+type browserFlags struct {
+	username    *string
+	password    *string
+	selectors   *string
+	debugDir    *string
+	userDataDir *string
+	headless    *bool
+	loadMedia   *bool
+	baseURL     *string
+	record      *string
+	replay      *string
+}
+
+// addBrowserFlags registers the flags shared by every command that drives chrome on fs
+func addBrowserFlags(fs *flag.FlagSet) *browserFlags {
+	return &browserFlags{
+		username:    fs.String("username", "", "x/twitter account to log into and delete tweets"),
+		password:    fs.String("password", "", "password for provided account"),
+		selectors:   fs.String("selectors", "", "path to a selector profile JSON file overriding the built in selectors"),
+		debugDir:    fs.String("debug-dir", ".", "directory to write debug bundles to when a step fails. set to empty to disable"),
+		userDataDir: fs.String("user-data-dir", "", "chrome profile directory used to save the x session between runs. a temporary profile is used if empty"),
+		headless:    fs.Bool("headless", false, "run chrome without a visible window"),
+		loadMedia:   fs.Bool("load-media", false, "let chrome load images, video, fonts and analytics, which are blocked by default to speed up page loads"),
+		baseURL:     fs.String("base-url", "", "site to delete tweets from, such as a mirror or a local fake x server. https://x.com is used if empty"),
+		record:      fs.String("record", "", "file to record chrome's CDP traffic and DOM snapshots to. contains session cookies and the password"),
+		replay:      fs.String("replay", "", "replay a file written by -record instead of launching chrome"),
+	}
+}
+
+// options builds the TweetDeleter options described by the flags, exiting if the selector profile or the
+// recording to replay can't be loaded
+func (f *browserFlags) options(logger *zap.Logger) internal.TweetDeleterOptions {
+	selectors := loadSelectors(logger, *f.selectors)
+
+	var driver internal.Driver
+	if *f.replay != "" {
+		var err error
+		driver, err = internal.NewReplayDriver(*f.replay)
+		if err != nil {
+			logger.Fatal("could not load recording to replay", zap.Error(err))
+		}
+	}
+
+	return internal.TweetDeleterOptions{
+		Username:    *f.username,
+		Password:    *f.password,
+		Logger:      logger,
+		DebugDir:    *f.debugDir,
+		Selectors:   selectors,
+		UserDataDir: *f.userDataDir,
+		Headless:    *f.headless,
+		LoadMedia:   *f.loadMedia,
+		BaseURL:     *f.baseURL,
+		Record:      *f.record,
+		Driver:      driver,
+	}
+}
+
+// apiFlags are the flags configuring the official X API, used by -mode api
+type apiFlags struct {
+	clientID     *string
+	clientSecret *string
+	callbackAddr *string
+	tokenFile    *string
+	baseURL      *string
+	likes        *bool
+}
+
+// addAPIFlags registers the flags configuring the official X API on fs
+func addAPIFlags(fs *flag.FlagSet) *apiFlags {
+	return &apiFlags{
+		clientID:     fs.String("client-id", "", "oauth 2.0 client id of an x app with api access. required with -mode api"),
+		clientSecret: fs.String("client-secret", "", "client secret of the x app, if it is a confidential client"),
+		callbackAddr: fs.String("callback-addr", "127.0.0.1:8723", "local address x redirects to after authorizing. http://<addr>/callback must be registered with the app"),
+		tokenFile:    fs.String("token-file", "", "file to save the api tokens to between runs. the app is authorized on every run if empty"),
+		baseURL:      fs.String("api-base-url", "", "x api to use, such as a local fake x api. https://api.x.com is used if empty"),
+		likes:        fs.Bool("likes", false, "with -mode http or api, also remove likes of tweets posted in the time range"),
+	}
+}
+
+// options builds the API options described by the flags
+func (f *apiFlags) options() internal.APIOptions {
+	return internal.APIOptions{
+		ClientID:     *f.clientID,
+		ClientSecret: *f.clientSecret,
+		CallbackAddr: *f.callbackAddr,
+		TokenFile:    *f.tokenFile,
+		BaseURL:      *f.baseURL,
+		Likes:        *f.likes,
+	}
+}
+
+// migrateFlags are the flags configuring copying tweets to another account before deleting them
+type migrateFlags struct {
+	archive     *string
+	to          *string
+	server      *string
+	accessToken *string
+	handle      *string
+	appPassword *string
+}
+
+// addMigrateFlags registers the flags configuring copying tweets to another account on fs
+func addMigrateFlags(fs *flag.FlagSet) *migrateFlags {
+	return &migrateFlags{
+		archive:     fs.String("migrate-archive", "", "x data archive, zipped or extracted, to copy tweets from to -migrate-to before deleting them. tweets aren't copied if empty"),
+		to:          fs.String("migrate-to", "", "platform to copy tweets to: mastodon or bluesky"),
+		server:      fs.String("migrate-server", "", "mastodon server, or bluesky pds, to copy tweets to. https://bsky.social is used for bluesky if empty"),
+		accessToken: fs.String("migrate-access-token", "", "access token of the mastodon account to copy tweets to"),
+		handle:      fs.String("migrate-handle", "", "handle of the bluesky account to copy tweets to"),
+		appPassword: fs.String("migrate-app-password", "", "app password of the bluesky account to copy tweets to"),
+	}
+}
+
+// options builds the migrate options described by the flags, exiting if the account to copy tweets to
+// isn't fully described
+func (f *migrateFlags) options(logger *zap.Logger) internal.MigrateOptions {
+	opts := internal.MigrateOptions{Archive: *f.archive}
+	if *f.archive == "" {
+		return opts
+	}
+	switch *f.to {
+	case "mastodon":
+		if *f.server == "" || *f.accessToken == "" {
+			logger.Fatal("migrate-server and migrate-access-token flags are required with -migrate-to mastodon")
+		}
+		opts.Mastodon = &internal.MastodonOptions{Server: *f.server, AccessToken: *f.accessToken}
+	case "bluesky":
+		if *f.handle == "" || *f.appPassword == "" {
+			logger.Fatal("migrate-handle and migrate-app-password flags are required with -migrate-to bluesky")
+		}
+		opts.Bluesky = &internal.BlueskyOptions{Handle: *f.handle, AppPassword: *f.appPassword, PDSURL: *f.server}
+	default:
+		logger.Fatal("migrate-to flag must be mastodon or bluesky with -migrate-archive", zap.String("migrateTo", *f.to))
+	}
+	return opts
+}
+
+// loadSelectors loads the selector profile at path, or the built in profile if path is empty, exiting
+// if it can't be loaded
+func loadSelectors(logger *zap.Logger, path string) *internal.SelectorProfile {
+	if path == "" {
+		return internal.DefaultSelectorProfile()
+	}
+	selectors, err := internal.LoadSelectorProfile(path)
+	if err != nil {
+		logger.Fatal("could not load selector profile", zap.Error(err))
+	}
+	return selectors
+}
+
+// parseDateRange parses the start and end date flags, exiting if either is missing or malformed or if
+// they aren't in order
+func parseDateRange(logger *zap.Logger, startDate, endDate string) (time.Time, time.Time) {
+	if startDate == "" {
+		logger.Fatal("start-date flag is required")
+	}
+	if endDate == "" {
+		logger.Fatal("end-date flag is required")
+	}
+
+	parsedStart, err := time.Parse(time.DateOnly, startDate)
+	if err != nil {
+		logger.Fatal("could not parse start date", zap.Error(err))
+	}
+	parsedEnd, err := time.Parse(time.DateOnly, endDate)
+	if err != nil {
+		logger.Fatal("could not parse end date", zap.Error(err))
+	}
+
+	if !parsedEnd.After(parsedStart) {
+		logger.Fatal("invalid start and end time. start time must be before end time",
+			zap.Time("startDate", parsedStart), zap.Time("endDate", parsedEnd))
+	}
+	return parsedStart, parsedEnd
+}
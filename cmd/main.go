@@ -3,7 +3,7 @@ package main
 import (
 	"flag"
 	"log"
-	"time"
+	"os"
 
 	"go.uber.org/zap"
 
@@ -16,51 +16,65 @@ func main() {
 		log.Fatal("Could not create zap logger")
 	}
 
-	username := flag.String("username", "", "x/twitter account to log into and delete tweets")
-	password := flag.String("password", "", "password for provided account")
-	startDate := flag.String("start-date", "", "start date of time range to delete tweets. must be formatted as YYYY-MM-DD")
-	endDate := flag.String("end-date", "", "end date (inclusive) of time range to delete tweets. must be formatted as YYYY-MM-DD")
+	args := os.Args[1:]
+	if len(args) > 0 {
+		var run func(*zap.Logger, []string) int
+		switch args[0] {
+		case "doctor":
+			run = runDoctor
+		case "selector-check":
+			run = runSelectorCheck
+		case "bluesky":
+			run = runBluesky
+		case "mastodon":
+			run = runMastodon
+		case "restore":
+			run = runRestore
+		}
+		if run != nil {
+			code := run(logger, args[1:])
+			_ = logger.Sync()
+			os.Exit(code)
+		}
+	}
 
-	flag.Parse()
+	fs := flag.NewFlagSet("tweetdeleter", flag.ExitOnError)
+	browser := addBrowserFlags(fs)
+	startDate := fs.String("start-date", "", "start date of time range to delete tweets. must be formatted as YYYY-MM-DD")
+	endDate := fs.String("end-date", "", "end date (inclusive) of time range to delete tweets. must be formatted as YYYY-MM-DD")
+	mode := fs.String("mode", string(internal.DeleteModeUI), "how to delete tweets. ui clicks through each tweet's menu, http calls x's api with the browser's session, batch calls it from the page for many tweets at a time, api uses the official x api without chrome")
+	api := addAPIFlags(fs)
+	migrate := addMigrateFlags(fs)
 
-	if *username == "" {
-		logger.Fatal("username flag is required")
-	}
-	if *password == "" {
-		logger.Fatal("password flag is required")
-	}
-	if *startDate == "" {
-		logger.Fatal("start-date flag is required")
-	}
-	if *endDate == "" {
-		logger.Fatal("end-date flag is required")
-	}
+	_ = fs.Parse(args)
 
-	parsedStart, err := time.Parse(time.DateOnly, *startDate)
-	if err != nil {
-		logger.Fatal("could not parse start date", zap.Error(err))
-	}
-	parsedEnd, err := time.Parse(time.DateOnly, *endDate)
-	if err != nil {
-		logger.Fatal("could not parse end date", zap.Error(err))
+	if internal.DeleteMode(*mode) != internal.DeleteModeAPI {
+		if *browser.username == "" {
+			logger.Fatal("username flag is required")
+		}
+		if *browser.password == "" {
+			logger.Fatal("password flag is required")
+		}
+	} else if *api.clientID == "" {
+		logger.Fatal("client-id flag is required with -mode api")
 	}
+	parsedStart, parsedEnd := parseDateRange(logger, *startDate, *endDate)
 
-	if !parsedEnd.After(parsedStart) {
-		logger.Fatal("invalid start and end time. start time must be before end time",
-			zap.Time("startDate", parsedStart), zap.Time("endDate", parsedEnd))
-	}
+	opts := browser.options(logger)
+	opts.StartDate = parsedStart
+	opts.EndDate = parsedEnd
+	opts.DeleteMode = internal.DeleteMode(*mode)
+	opts.API = api.options()
+	opts.Likes = opts.API.Likes
+	opts.Migrate = migrate.options(logger)
 
-	td, err := internal.NewTweetDeleter(internal.TweetDeleterOptions{
-		Username:  *username,
-		Password:  *password,
-		StartDate: parsedStart,
-		EndDate:   parsedEnd,
-		Logger:    logger,
-	})
+	td, err := internal.NewTweetDeleter(opts)
 	if err != nil {
 		logger.Fatal("could not create TweetDeleter", zap.Error(err))
 	}
 	if err = td.Run(); err != nil {
 		logger.Error("error running TweetDeleter", zap.Error(err))
+		_ = logger.Sync()
+		os.Exit(exitCode(err))
 	}
 }
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+
+	"go.uber.org/zap"
+
+	"tweetdeleter/internal"
+)
+
+// runMastodon deletes the statuses and boosts a Mastodon account posted in a time range, and optionally
+// its favourites. It returns the process exit code.
+func runMastodon(logger *zap.Logger, args []string) int {
+	fs := flag.NewFlagSet("tweetdeleter mastodon", flag.ExitOnError)
+	server := fs.String("server", "", "mastodon server hosting the account, such as https://mastodon.social")
+	accessToken := fs.String("access-token", "", "access token of an application created in the account's development settings")
+	startDate := fs.String("start-date", "", "start date of time range to delete statuses. must be formatted as YYYY-MM-DD")
+	endDate := fs.String("end-date", "", "end date of time range to delete statuses. must be formatted as YYYY-MM-DD")
+	likes := fs.Bool("likes", false, "also remove favourites of statuses posted in the time range")
+	_ = fs.Parse(args)
+
+	if *server == "" {
+		logger.Fatal("server flag is required")
+	}
+	if *accessToken == "" {
+		logger.Fatal("access-token flag is required")
+	}
+	parsedStart, parsedEnd := parseDateRange(logger, *startDate, *endDate)
+
+	md, err := internal.NewMastodonDeleter(internal.MastodonOptions{
+		Server:      *server,
+		AccessToken: *accessToken,
+		StartDate:   parsedStart,
+		EndDate:     parsedEnd,
+		Logger:      logger,
+		Likes:       *likes,
+	})
+	if err != nil {
+		logger.Fatal("could not create MastodonDeleter", zap.Error(err))
+	}
+	if err := md.Run(); err != nil {
+		logger.Error("error running MastodonDeleter", zap.Error(err))
+		return exitCode(err)
+	}
+	return exitOK
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"go.uber.org/zap"
+
+	"tweetdeleter/internal"
+)
+
+// runRestore reposts the tweets in an archive that were posted in a time range, to recover from
+// deleting the wrong tweets. It returns the process exit code.
+func runRestore(logger *zap.Logger, args []string) int {
+	fs := flag.NewFlagSet("tweetdeleter restore", flag.ExitOnError)
+	browser := addBrowserFlags(fs)
+	archive := fs.String("archive", "", "x data archive, zipped or extracted, downloaded before the tweets were deleted")
+	startDate := fs.String("start-date", "", "start date of time range to restore tweets from. must be formatted as YYYY-MM-DD")
+	endDate := fs.String("end-date", "", "end date of time range to restore tweets from. must be formatted as YYYY-MM-DD")
+	attributeDate := fs.Bool("attribute-date", false, "end each restored tweet with the date it was originally posted")
+	delay := fs.Duration("delay", time.Minute, "pause between posts, to stay under x's limits on how often an account can post")
+	fromTweet := fs.String("from-tweet", "", "id of the first tweet to restore, to resume a restore that stopped part way")
+	dryRun := fs.Bool("dry-run", false, "log the tweets that would be restored without launching chrome or posting anything")
+	_ = fs.Parse(args)
+
+	if *archive == "" {
+		logger.Fatal("archive flag is required")
+	}
+	if !*dryRun {
+		if *browser.username == "" {
+			logger.Fatal("username flag is required")
+		}
+		if *browser.password == "" {
+			logger.Fatal("password flag is required")
+		}
+	}
+	parsedStart, parsedEnd := parseDateRange(logger, *startDate, *endDate)
+
+	opts := browser.options(logger)
+	opts.StartDate = parsedStart
+	opts.EndDate = parsedEnd
+
+	td, err := internal.NewTweetDeleter(opts)
+	if err != nil {
+		logger.Fatal("could not create TweetDeleter", zap.Error(err))
+	}
+	err = td.Restore(internal.RestoreOptions{
+		Archive:       *archive,
+		AttributeDate: *attributeDate,
+		Delay:         *delay,
+		FromTweet:     *fromTweet,
+		DryRun:        *dryRun,
+	})
+	if err != nil {
+		logger.Error("error restoring tweets", zap.Error(err))
+		return exitCode(err)
+	}
+	return exitOK
+}
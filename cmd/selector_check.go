@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+
+	"go.uber.org/zap"
+
+	"tweetdeleter/internal"
+)
+
+// runSelectorCheck checks a selector profile against saved snapshots of X's pages in headless chrome
+// and prints a table of the results. It returns the process exit code, which is non-zero if any
+// element didn't resolve to exactly the elements its fixture expects.
+func runSelectorCheck(logger *zap.Logger, args []string) int {
+	fs := flag.NewFlagSet("tweetdeleter selector-check", flag.ExitOnError)
+	fixtures := fs.String("fixtures", "fixtures/selectors", "directory of .html page snapshots whose expected elements are marked with data-tweetdeleter-expect")
+	selectors := fs.String("selectors", "", "path to a selector profile JSON file to check instead of the built in selectors")
+	_ = fs.Parse(args)
+
+	td, err := internal.NewTweetDeleter(internal.TweetDeleterOptions{
+		Logger:    logger,
+		Selectors: loadSelectors(logger, *selectors),
+		Headless:  true,
+	})
+	if err != nil {
+		logger.Fatal("could not create TweetDeleter", zap.Error(err))
+	}
+	results, err := td.CheckSelectors(*fixtures)
+	if err != nil {
+		logger.Error("could not check selectors", zap.Error(err))
+		return exitError
+	}
+	return printResults(results)
+}
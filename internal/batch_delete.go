@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// batchSize is how many tweets are deleted by each run of the batch script
+	batchSize = 20
+	// batchDelay is the pause between the API calls made by the batch script, which keeps a batch
+	// well under X's rate limits and within stepTimeout
+	batchDelay = time.Second
+)
+
+// batchDeleteScript implements ScriptBatchDelete. It calls the DeleteTweet mutation the same way
+// X's web client does, so the calls carry the page's cookies and show up in its network traffic.
+var batchDeleteScript = Script{Name: ScriptBatchDelete, Source: `async (ids, attr, bearer, queryId, delay) => {
+	const csrf = document.cookie.match(/(?:^|;\s*)ct0=([^;]*)/)?.[1] ?? "";
+	const results = [];
+	for (const [i, id] of ids.entries()) {
+		if (i > 0) {
+			await new Promise((resolve) => setTimeout(resolve, delay));
+		}
+		let result;
+		try {
+			const resp = await fetch("/i/api/graphql/" + queryId + "/DeleteTweet", {
+				method: "POST",
+				credentials: "include",
+				headers: {
+					"Authorization": "Bearer " + bearer,
+					"Content-Type": "application/json",
+					"X-Csrf-Token": csrf,
+					"X-Twitter-Auth-Type": "OAuth2Session",
+					"X-Twitter-Active-User": "yes",
+				},
+				body: JSON.stringify({ variables: { tweet_id: id, dark_request: false }, queryId }),
+			});
+			const body = await resp.json().catch(() => null);
+			const error = resp.ok ? body?.errors?.[0]?.message ?? "" : resp.status + " " + resp.statusText;
+			result = { id, status: resp.status, error };
+		} catch (e) {
+			result = { id, status: 0, error: String(e) };
+		}
+		results.push(result);
+		if (result.error !== "") {
+			break;
+		}
+		document.querySelector("[" + attr + "=\"" + CSS.escape(id) + "\"]")?.remove();
+	}
+	return results;
+}`}
+
+// batchResult is the outcome of deleting one tweet of a batch
+type batchResult struct {
+	ID     string `json:"id"`
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+}
+
+// batchBackend deletes the tweets shown on the page in batches, by running a script in the page that
+// calls X's DeleteTweet mutation for each of them. This avoids a round trip between Go and the page for
+// every click, while the calls stay within the browser's logged in session.
+type batchBackend struct {
+	client *graphqlClient
+}
+
+// listen watches the page's API calls for the bearer token to send
+func (b *batchBackend) listen(page Page) {
+	b.client.listen(page)
+}
+
+func (b *batchBackend) deleteTweet(s *browserSession, search searchPage, id string, queued []string) ([]string, error) {
+	ids := append([]string{id}, queued[:min(len(queued), batchSize-1)]...)
+
+	var results []batchResult
+	err := runStep(s.ctx, s.page, func(ctx context.Context, p Page) error {
+		return p.Evaluate(ctx, batchDeleteScript, &results,
+			ids, tweetIDAttr, b.client.bearerToken(), deleteTweetMutation.queryID, batchDelay.Milliseconds())
+	})
+	var deleted []string
+	for _, r := range results {
+		if r.Error != "" {
+			return deleted, fmt.Errorf("could not delete tweet %s: %s", r.ID, r.Error)
+		}
+		deleted = append(deleted, r.ID)
+	}
+	return deleted, err
+}
@@ -0,0 +1,419 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultPDSURL is the personal data server that hosts most Bluesky accounts
+	defaultPDSURL = "https://bsky.social"
+	// blueskyMaxChars is how long posts can be
+	blueskyMaxChars = 300
+	// blueskyMaxImages is how many images can be embedded in a post
+	blueskyMaxImages = 4
+)
+
+// Bluesky collections holding the records deleted by BlueskyDeleter
+const (
+	blueskyPostCollection   = "app.bsky.feed.post"
+	blueskyRepostCollection = "app.bsky.feed.repost"
+	blueskyLikeCollection   = "app.bsky.feed.like"
+)
+
+// BlueskyOptions configure a BlueskyDeleter
+type BlueskyOptions struct {
+	// Handle is the handle, or email address, of the account to delete posts from
+	Handle string
+	// AppPassword is an app password created in Bluesky's settings. The account's own password works
+	// too, but app passwords can be revoked.
+	AppPassword string
+	StartDate   time.Time
+	EndDate     time.Time
+	Logger      *zap.Logger
+	// PDSURL is the scheme and host of the personal data server hosting the account, such as a
+	// self-hosted PDS or a local mock served by the fakebsky package. https://bsky.social is used if
+	// PDSURL is empty.
+	PDSURL string
+	// Likes also removes the account's likes made in the time range
+	Likes bool
+}
+
+// BlueskyDeleter deletes the posts and reposts a Bluesky account made in a time range, and
+// optionally its likes, through the AT Protocol
+type BlueskyDeleter struct {
+	logger    *zap.Logger
+	client    *blueskyClient
+	startDate time.Time
+	endDate   time.Time
+	rateLimit *rateLimitMonitor
+}
+
+// NewBlueskyDeleter creates a BlueskyDeleter configured by opts
+func NewBlueskyDeleter(opts BlueskyOptions) (*BlueskyDeleter, error) {
+	rateLimit := &rateLimitMonitor{}
+	client, err := newBlueskyClient(opts, opts.Logger, rateLimit)
+	if err != nil {
+		return nil, err
+	}
+	return &BlueskyDeleter{
+		logger:    opts.Logger,
+		client:    client,
+		startDate: opts.StartDate,
+		endDate:   opts.EndDate,
+		rateLimit: rateLimit,
+	}, nil
+}
+
+// Run logs in and deletes the account's records in the time range. Throttled calls are retried once
+// the PDS is willing to talk to us again.
+func (b *BlueskyDeleter) Run() error {
+	p := &purge{
+		platform:  b.client,
+		logger:    b.logger,
+		rateLimit: b.rateLimit,
+		endDate:   b.endDate,
+		noun:      "records",
+		progress:  progress{since: b.startDate},
+	}
+	return p.run(context.Background())
+}
+
+// blueskySession is the session created by logging in
+type blueskySession struct {
+	DID        string `json:"did"`
+	AccessJwt  string `json:"accessJwt"`
+	RefreshJwt string `json:"refreshJwt"`
+}
+
+// blueskyRecord is a record listed from a collection. Only the fields needed to delete it are decoded.
+type blueskyRecord struct {
+	URI   string `json:"uri"`
+	Value struct {
+		CreatedAt string `json:"createdAt"`
+	} `json:"value"`
+}
+
+// blueskyRecordPage is a page of records along with the cursor of the next page
+type blueskyRecordPage struct {
+	Records []blueskyRecord `json:"records"`
+	Cursor  string          `json:"cursor"`
+}
+
+// xrpcError is the body of an error response from an XRPC endpoint
+type xrpcError struct {
+	Status  string `json:"-"`
+	Name    string `json:"error"`
+	Message string `json:"message"`
+}
+
+func (e *xrpcError) Error() string {
+	if e.Message == "" {
+		return e.Status + ": " + e.Name
+	}
+	return e.Status + ": " + e.Name + ": " + e.Message
+}
+
+// blueskyClient calls the XRPC endpoints of a PDS used to find and delete an account's records. It is
+// the Platform for Bluesky, and a mirror tweets can be copied to.
+type blueskyClient struct {
+	pdsURL      string
+	handle      string
+	appPassword string
+	collections []string // collections holding the records to delete
+	http        *http.Client
+	rateLimit   *rateLimitMonitor
+	logger      *zap.Logger
+	session     blueskySession
+}
+
+// newBlueskyClient creates a client for the account configured by opts, recording throttled calls in
+// rateLimit
+func newBlueskyClient(opts BlueskyOptions, logger *zap.Logger, rateLimit *rateLimitMonitor) (*blueskyClient, error) {
+	if opts.Handle == "" || opts.AppPassword == "" {
+		return nil, errors.New("a handle and app password are required to log into bluesky")
+	}
+	pdsURL := defaultPDSURL
+	if opts.PDSURL != "" {
+		u, err := url.Parse(opts.PDSURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid pds url %q", opts.PDSURL)
+		}
+		pdsURL = strings.TrimSuffix(opts.PDSURL, "/")
+	}
+
+	collections := []string{blueskyPostCollection, blueskyRepostCollection}
+	if opts.Likes {
+		collections = append(collections, blueskyLikeCollection)
+	}
+	return &blueskyClient{
+		pdsURL:      pdsURL,
+		handle:      opts.Handle,
+		appPassword: opts.AppPassword,
+		collections: collections,
+		http:        &http.Client{Timeout: stepTimeout},
+		rateLimit:   rateLimit,
+		logger:      logger,
+	}, nil
+}
+
+// Login creates a session for the account
+func (c *blueskyClient) Login(ctx context.Context) error {
+	if err := c.login(ctx); err != nil {
+		return err
+	}
+	c.logger.Info("successfully logged in", zap.String("handle", c.handle), zap.String("did", c.session.DID))
+	return nil
+}
+
+// Enumerate returns the account's records that were created in window. Records are listed by record
+// key rather than by date, and imported posts can be backdated, so every collection is listed in full.
+func (c *blueskyClient) Enumerate(ctx context.Context, window Window) ([]Item, error) {
+	kinds := map[string]ItemKind{
+		blueskyPostCollection:   ItemPost,
+		blueskyRepostCollection: ItemRepost,
+		blueskyLikeCollection:   ItemLike,
+	}
+	var items []Item
+	for _, collection := range c.collections {
+		for cursor := ""; ; {
+			page, err := c.listRecords(ctx, collection, cursor)
+			if err != nil {
+				return nil, err
+			}
+			for _, record := range page.Records {
+				createdAt, err := time.Parse(time.RFC3339Nano, record.Value.CreatedAt)
+				if err != nil {
+					c.logger.Warn("skipping record without a valid creation time", zap.String("uri", record.URI), zap.Error(err))
+					continue
+				}
+				if !createdAt.Before(window.Since) && createdAt.Before(window.Until) {
+					items = append(items, Item{ID: record.URI, Kind: kinds[collection], CreatedAt: createdAt})
+				}
+			}
+			if len(page.Records) == 0 || page.Cursor == "" {
+				break
+			}
+			cursor = page.Cursor
+		}
+	}
+	return items, nil
+}
+
+// Delete deletes the record whose at:// uri is the item's id
+func (c *blueskyClient) Delete(ctx context.Context, item Item) error {
+	// at://<did>/<collection>/<rkey>
+	parts := strings.Split(strings.TrimPrefix(item.ID, "at://"), "/")
+	if len(parts) != 3 {
+		return fmt.Errorf("invalid record uri %q", item.ID)
+	}
+	if err := c.deleteRecord(ctx, parts[1], parts[2]); err != nil {
+		return fmt.Errorf("could not delete %s: %w", item.ID, err)
+	}
+	return nil
+}
+
+// login creates a session with the account's handle and app password. Rejected credentials, two factor
+// prompts and suspended accounts are reported as ErrLoginFailed, ErrChallengeRequired and
+// ErrAccountLocked.
+func (c *blueskyClient) login(ctx context.Context) error {
+	body := map[string]string{"identifier": c.handle, "password": c.appPassword}
+	err := c.send(ctx, http.MethodPost, "com.atproto.server.createSession", nil, body, "", &c.session)
+
+	var xerr *xrpcError
+	if errors.As(err, &xerr) {
+		switch xerr.Name {
+		case "AuthFactorTokenRequired":
+			return fmt.Errorf("%w: %w", ErrChallengeRequired, err)
+		case "AccountTakedown", "AccountSuspended", "AccountDeactivated":
+			return fmt.Errorf("%w: %w", ErrAccountLocked, err)
+		case "AuthenticationRequired":
+			return fmt.Errorf("%w: %w", ErrLoginFailed, err)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error while attempting to login: %w", err)
+	}
+	return nil
+}
+
+// refresh renews the session's access token, logging in again if the refresh token has expired too
+func (c *blueskyClient) refresh(ctx context.Context) error {
+	var session blueskySession
+	err := c.send(ctx, http.MethodPost, "com.atproto.server.refreshSession", nil, nil, c.session.RefreshJwt, &session)
+	if errors.Is(err, ErrRateLimited) {
+		return err
+	}
+	if err != nil {
+		return c.login(ctx)
+	}
+	c.session = session
+	return nil
+}
+
+// listRecords returns a page of the account's records in collection, newest first
+func (c *blueskyClient) listRecords(ctx context.Context, collection, cursor string) (*blueskyRecordPage, error) {
+	q := url.Values{"repo": {c.session.DID}, "collection": {collection}, "limit": {"100"}}
+	if cursor != "" {
+		q.Set("cursor", cursor)
+	}
+	var page blueskyRecordPage
+	err := c.call(ctx, http.MethodGet, "com.atproto.repo.listRecords", q, nil, &page)
+	return &page, err
+}
+
+// deleteRecord deletes the account's record with rkey from collection
+func (c *blueskyClient) deleteRecord(ctx context.Context, collection, rkey string) error {
+	body := map[string]string{"repo": c.session.DID, "collection": collection, "rkey": rkey}
+	return c.call(ctx, http.MethodPost, "com.atproto.repo.deleteRecord", nil, body, nil)
+}
+
+// crossPost posts a copy of tweet dated when the tweet was posted, with up to four of the tweet's images
+// attached. Bluesky only takes video through a separate service, so videos are left out. The copy's
+// record key is derived from the tweet, so copying a tweet again replaces its copy instead of posting
+// it twice.
+func (c *blueskyClient) crossPost(ctx context.Context, tweet archivedTweet, media []archivedMedia) (string, error) {
+	var images []map[string]interface{}
+	for _, m := range media {
+		if !strings.HasPrefix(m.MimeType, "image/") || len(images) == blueskyMaxImages {
+			c.logger.Warn("leaving media out of the copy of tweet", zap.String("tweetID", tweet.ID), zap.String("media", m.Name))
+			continue
+		}
+		var res struct {
+			Blob json.RawMessage `json:"blob"`
+		}
+		if err := c.call(ctx, http.MethodPost, "com.atproto.repo.uploadBlob", nil, rawBody{data: m.Data, contentType: m.MimeType}, &res); err != nil {
+			return "", fmt.Errorf("could not upload %s: %w", m.Name, err)
+		}
+		images = append(images, map[string]interface{}{"alt": "", "image": res.Blob})
+	}
+
+	record := map[string]interface{}{
+		"$type":     blueskyPostCollection,
+		"text":      crossPostText(tweet, blueskyMaxChars),
+		"createdAt": tweet.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if len(images) > 0 {
+		record["embed"] = map[string]interface{}{"$type": "app.bsky.embed.images", "images": images}
+	}
+	body := map[string]interface{}{
+		"repo":       c.session.DID,
+		"collection": blueskyPostCollection,
+		"rkey":       blueskyTID(tweet),
+		"record":     record,
+	}
+	var res struct {
+		URI string `json:"uri"`
+	}
+	if err := c.call(ctx, http.MethodPost, "com.atproto.repo.putRecord", nil, body, &res); err != nil {
+		return "", err
+	}
+	return res.URI, nil
+}
+
+// blueskyTID returns the record key of the copy of tweet. Like Bluesky's timestamp identifiers it is the
+// base32 encoding of the time the post was created in microseconds followed by a 10 bit clock id, which
+// is taken from the tweet's id.
+func blueskyTID(tweet archivedTweet) string {
+	const alphabet = "234567abcdefghijklmnopqrstuvwxyz"
+	id, _ := strconv.ParseUint(tweet.ID, 10, 64)
+	v := uint64(tweet.CreatedAt.UnixMicro())<<10 | id&0x3ff
+	tid := make([]byte, 13)
+	for i := len(tid) - 1; i >= 0; i-- {
+		tid[i] = alphabet[v&31]
+		v >>= 5
+	}
+	return string(tid)
+}
+
+// call calls the endpoint nsid with the session's access token, refreshing it and retrying once if
+// the PDS says it has expired
+func (c *blueskyClient) call(ctx context.Context, method, nsid string, query url.Values, body, res interface{}) error {
+	err := c.send(ctx, method, nsid, query, body, c.session.AccessJwt, res)
+	var xerr *xrpcError
+	if !errors.As(err, &xerr) || xerr.Name != "ExpiredToken" {
+		return err
+	}
+	if err := c.refresh(ctx); err != nil {
+		return err
+	}
+	return c.send(ctx, method, nsid, query, body, c.session.AccessJwt, res)
+}
+
+// rawBody is a request body that is sent as is rather than as JSON
+type rawBody struct {
+	data        []byte
+	contentType string
+}
+
+// send calls the endpoint nsid with token, sending body as JSON if it isn't nil or a rawBody, and decodes
+// its response into res. Error responses are returned as *xrpcError.
+func (c *blueskyClient) send(ctx context.Context, method, nsid string, query url.Values, body interface{}, token string, res interface{}) error {
+	u := c.pdsURL + "/xrpc/" + nsid
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reqBody io.Reader
+	var contentType string
+	switch b := body.(type) {
+	case nil:
+	case rawBody:
+		reqBody, contentType = bytes.NewReader(b.data), b.contentType
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody, contentType = bytes.NewReader(data), "application/json"
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", nsid, err)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", nsid, err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		c.rateLimit.throttledResponse(resp)
+		return fmt.Errorf("%w: %s: %s", ErrRateLimited, nsid, resp.Status)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		xerr := &xrpcError{Status: resp.Status}
+		if json.Unmarshal(data, xerr) != nil || xerr.Name == "" {
+			xerr.Message = strings.TrimSpace(string(data))
+		}
+		return fmt.Errorf("%s failed: %w", nsid, xerr)
+	}
+	if res == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, res); err != nil {
+		return fmt.Errorf("%s returned an unexpected response: %w", nsid, err)
+	}
+	return nil
+}
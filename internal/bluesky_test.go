@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"tweetdeleter/internal/fakebsky"
+)
+
+// Posts, reposts and likes in the time range are deleted, paging through collections longer than a
+// page of listRecords, while the records before and after it are kept
+func TestBlueskyPurgeDeletesPostsRepostsAndLikes(t *testing.T) {
+	since := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	var records []fakebsky.Record
+	records = append(records, fakebsky.GenerateRecords(fakebsky.PostCollection, 250, since, until)...)
+	records = append(records, fakebsky.GenerateRecords(fakebsky.RepostCollection, 20, since, until)...)
+	records = append(records, fakebsky.GenerateRecords(fakebsky.LikeCollection, 20, since, until)...)
+	before := fakebsky.GenerateRecords(fakebsky.PostCollection, 5, since.AddDate(-1, 0, 0), since)
+	after := fakebsky.GenerateRecords(fakebsky.LikeCollection, 5, until, until.AddDate(0, 1, 0))
+	records = append(append(records, before...), after...)
+
+	pds := fakebsky.NewPDS(fakebsky.Options{Handle: "fake.bsky.social", AppPassword: "app-password", Records: records})
+	server := fakebsky.NewServer(pds)
+	defer server.Close()
+
+	bd, err := NewBlueskyDeleter(BlueskyOptions{
+		Handle:      "fake.bsky.social",
+		AppPassword: "app-password",
+		StartDate:   since,
+		EndDate:     until,
+		Logger:      zap.NewNop(),
+		PDSURL:      server.URL,
+		Likes:       true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bd.Run(); err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+
+	if deleted := len(pds.Deleted()); deleted != 290 {
+		t.Errorf("deleted %d records, want 290", deleted)
+	}
+	if left := pds.Records(fakebsky.PostCollection); len(left) != len(before) {
+		t.Errorf("%d posts left, want the %d from before the time range", len(left), len(before))
+	}
+	if left := pds.Records(fakebsky.RepostCollection); len(left) != 0 {
+		t.Errorf("%d reposts left, want 0", len(left))
+	}
+	if left := pds.Records(fakebsky.LikeCollection); len(left) != len(after) {
+		t.Errorf("%d likes left, want the %d from after the time range", len(left), len(after))
+	}
+}
@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// cdpRecordingVersion is the version of the recording format written by cdpRecorder
+const cdpRecordingVersion = 1
+
+// cdpRecording is the CDP traffic between chromedp and chrome for every browser launched during
+// a run, along with snapshots of the DOM taken along the way. It is written by cdpRecorder and
+// served back to chromedp by the replay driver.
+type cdpRecording struct {
+	Version  int          `json:"version"`
+	Sessions []cdpSession `json:"sessions"`
+}
+
+// cdpSession is the recorded traffic of a single browser
+type cdpSession struct {
+	Messages  []cdpMessage  `json:"messages"`
+	Snapshots []domSnapshot `json:"snapshots"`
+}
+
+// cdpMessage is a single CDP message in the order it was sent or received
+type cdpMessage struct {
+	// Sent is true for commands sent by chromedp and false for the responses and events sent by chrome
+	Sent bool            `json:"sent"`
+	Data json.RawMessage `json:"data"`
+}
+
+// domSnapshot is the page's HTML after an action
+type domSnapshot struct {
+	// After describes the action, such as "click tweet.moreButton" or "navigate https://x.com/home"
+	After string `json:"after"`
+	URL   string `json:"url"`
+	HTML  string `json:"html"`
+}
+
+// cdpRecorder records the CDP traffic and DOM snapshots of a run to a file
+type cdpRecorder struct {
+	path string
+
+	mu        sync.Mutex
+	recording cdpRecording
+}
+
+// newCDPRecorder creates a recorder that writes its recording to path
+func newCDPRecorder(path string) *cdpRecorder {
+	return &cdpRecorder{path: path, recording: cdpRecording{Version: cdpRecordingVersion}}
+}
+
+// newSession starts recording a new browser
+func (r *cdpRecorder) newSession() *cdpSessionRecorder {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recording.Sessions = append(r.recording.Sessions, cdpSession{})
+	return &cdpSessionRecorder{recorder: r, index: len(r.recording.Sessions) - 1}
+}
+
+// cdpSessionRecorder records the traffic of a single browser
+type cdpSessionRecorder struct {
+	recorder *cdpRecorder
+	index    int
+}
+
+// logf is used as chromedp's browser debug logger, which is given every message sent to chrome
+// as "-> %s" and every message received from it as "<- %s"
+func (s *cdpSessionRecorder) logf(format string, args ...interface{}) {
+	var sent bool
+	switch format {
+	case "-> %s":
+		sent = true
+	case "<- %s":
+	default:
+		return
+	}
+	data, ok := args[0].([]byte)
+	if !ok || !json.Valid(data) {
+		return
+	}
+
+	r := s.recorder
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session := &r.recording.Sessions[s.index]
+	session.Messages = append(session.Messages, cdpMessage{Sent: sent, Data: append(json.RawMessage(nil), data...)})
+}
+
+// snapshot records the page's HTML after the action described by after
+func (s *cdpSessionRecorder) snapshot(after, url, html string) {
+	r := s.recorder
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session := &r.recording.Sessions[s.index]
+	session.Snapshots = append(session.Snapshots, domSnapshot{After: after, URL: url, HTML: html})
+}
+
+// save writes everything recorded so far. The recording contains the session's cookies and
+// everything typed into the page, so it is only readable by the current user.
+func (s *cdpSessionRecorder) save() error {
+	r := s.recorder
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := json.Marshal(r.recording)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, data, 0o600)
+}
+
+// loadCDPRecording reads a recording written by cdpRecorder
+func loadCDPRecording(path string) (*cdpRecording, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var recording cdpRecording
+	if err := json.Unmarshal(data, &recording); err != nil {
+		return nil, fmt.Errorf("could not parse CDP recording %s: %w", path, err)
+	}
+	if recording.Version != cdpRecordingVersion {
+		return nil, fmt.Errorf("CDP recording %s has unsupported version %d", path, recording.Version)
+	}
+	return &recording, nil
+}
@@ -0,0 +1,280 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// replayDriver serves recorded CDP traffic back to chromedp instead of launching chrome
+type replayDriver struct {
+	recording *cdpRecording
+
+	mu   sync.Mutex
+	next int
+}
+
+// NewReplayDriver creates a driver that replays a recording written by a run with
+// TweetDeleterOptions.Record set, without launching chrome or talking to X. Each page replays the
+// next browser session in the recording. Commands are answered with the recorded response to the
+// first unanswered recorded command with the same method and parameters, falling back to the same
+// method when the parameters differ, and are followed by the events chrome sent before the next
+// recorded command, so the same run replays the same way every time.
+func NewReplayDriver(path string) (Driver, error) {
+	recording, err := loadCDPRecording(path)
+	if err != nil {
+		return nil, err
+	}
+	return &replayDriver{recording: recording}, nil
+}
+
+// NewPage starts serving the next recorded browser session and connects chromedp to it
+func (d *replayDriver) NewPage() (Page, error) {
+	d.mu.Lock()
+	if d.next == len(d.recording.Sessions) {
+		d.mu.Unlock()
+		return nil, fmt.Errorf("the recording only has %d browser sessions", len(d.recording.Sessions))
+	}
+	session := newReplaySession(d.recording.Sessions[d.next])
+	d.next++
+	d.mu.Unlock()
+
+	server, err := newReplayServer(session)
+	if err != nil {
+		return nil, err
+	}
+	// Whatever was blocked while recording is already missing from the recorded traffic
+	page, err := (&chromeDriver{opts: chromeOptions{remoteURL: server.url, loadMedia: true}}).NewPage()
+	if err != nil {
+		server.close()
+		return nil, err
+	}
+	return &replayPage{Page: page, server: server}, nil
+}
+
+// replayPage is a chrome page connected to a replay server
+type replayPage struct {
+	Page
+	server *replayServer
+}
+
+// Close disconnects from the replay server and stops it
+func (p *replayPage) Close() {
+	p.Page.Close()
+	p.server.close()
+}
+
+// cdpEnvelope is the part of a CDP message needed to route it
+type cdpEnvelope struct {
+	ID        int64           `json:"id,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+}
+
+// replayCommand is a recorded command along with chrome's response to it
+type replayCommand struct {
+	pos         int // position of the command in the recording
+	method      string
+	sessionID   string
+	params      []byte
+	response    []byte
+	responsePos int
+	answered    bool
+}
+
+// replayEvent is a recorded event
+type replayEvent struct {
+	pos  int
+	data []byte
+}
+
+// replaySession answers commands from the traffic recorded for one browser
+type replaySession struct {
+	mu       sync.Mutex
+	commands []*replayCommand
+	events   []replayEvent
+	sent     int // number of events sent
+}
+
+// newReplaySession indexes the commands, responses and events recorded in session
+func newReplaySession(session cdpSession) *replaySession {
+	s := &replaySession{}
+	byID := make(map[int64]*replayCommand)
+	for pos, msg := range session.Messages {
+		var env cdpEnvelope
+		if json.Unmarshal(msg.Data, &env) != nil {
+			continue
+		}
+		switch {
+		case msg.Sent:
+			c := &replayCommand{pos: pos, method: env.Method, sessionID: env.SessionID, params: compactJSON(env.Params)}
+			s.commands = append(s.commands, c)
+			byID[env.ID] = c
+		case env.ID != 0:
+			if c := byID[env.ID]; c != nil && c.response == nil {
+				c.response, c.responsePos = msg.Data, pos
+			}
+		case env.Method != "":
+			s.events = append(s.events, replayEvent{pos: pos, data: msg.Data})
+		}
+	}
+	return s
+}
+
+// reply returns the messages to send in reply to the command data: the events recorded before the
+// response, the response, and the events recorded after it up to the next recorded command
+func (s *replaySession) reply(data []byte) ([][]byte, error) {
+	var env cdpEnvelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("could not parse command: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.match(env.Method, env.SessionID, compactJSON(env.Params))
+	if i < 0 {
+		return [][]byte{replayError(env, "no recorded response to "+env.Method)}, nil
+	}
+	c := s.commands[i]
+	c.answered = true
+
+	next := math.MaxInt
+	if i+1 < len(s.commands) {
+		next = s.commands[i+1].pos
+	}
+	var out [][]byte
+	for ; s.sent < len(s.events) && s.events[s.sent].pos < c.responsePos; s.sent++ {
+		out = append(out, s.events[s.sent].data)
+	}
+	response, err := withID(c.response, env.ID)
+	if err != nil {
+		return nil, err
+	}
+	out = append(out, response)
+	for ; s.sent < len(s.events) && s.events[s.sent].pos < next; s.sent++ {
+		out = append(out, s.events[s.sent].data)
+	}
+	return out, nil
+}
+
+// match returns the index of the first unanswered command with a response that has the same method,
+// session and parameters, or the same method and session if none have the same parameters. It
+// returns -1 if there is no such command.
+func (s *replaySession) match(method, sessionID string, params []byte) int {
+	fallback := -1
+	for i, c := range s.commands {
+		if c.answered || c.response == nil || c.method != method || c.sessionID != sessionID {
+			continue
+		}
+		if bytes.Equal(c.params, params) {
+			return i
+		}
+		if fallback < 0 {
+			fallback = i
+		}
+	}
+	return fallback
+}
+
+// withID returns the recorded response with its id replaced by id
+func withID(response []byte, id int64) ([]byte, error) {
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(response, &msg); err != nil {
+		return nil, fmt.Errorf("could not parse recorded response: %w", err)
+	}
+	msg["id"] = json.RawMessage(fmt.Sprint(id))
+	return json.Marshal(msg)
+}
+
+// replayError is an error response to the command env
+func replayError(env cdpEnvelope, message string) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"id":        env.ID,
+		"sessionId": env.SessionID,
+		"error":     map[string]interface{}{"code": -32000, "message": message},
+	})
+	return data
+}
+
+// compactJSON returns data without insignificant whitespace so that parameters can be compared
+func compactJSON(data []byte) []byte {
+	var buf bytes.Buffer
+	if json.Compact(&buf, data) != nil {
+		return data
+	}
+	return buf.Bytes()
+}
+
+// replayServer is a websocket server that chromedp connects to as if it were chrome
+type replayServer struct {
+	url     string
+	server  *http.Server
+	session *replaySession
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+// newReplayServer starts serving session on a local port
+func newReplayServer(session *replaySession) (*replayServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("could not start the replay server: %w", err)
+	}
+
+	s := &replayServer{url: "ws://" + listener.Addr().String() + "/devtools/browser/replay", session: session}
+	s.server = &http.Server{Handler: s}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.close()
+		}
+	}()
+	return s, nil
+}
+
+// ServeHTTP upgrades the connection to a websocket and answers the commands sent over it
+func (s *replayServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, _, _, err := ws.UpgradeHTTP(r, w)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.conns = append(s.conns, conn)
+	s.mu.Unlock()
+	defer conn.Close()
+
+	for {
+		data, err := wsutil.ReadClientText(conn)
+		if err != nil {
+			return
+		}
+		out, err := s.session.reply(data)
+		if err != nil {
+			return
+		}
+		for _, msg := range out {
+			if err := wsutil.WriteServerText(conn, msg); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// close stops the server and closes its connections
+func (s *replayServer) close() {
+	s.server.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
@@ -0,0 +1,95 @@
+package internal_test
+
+import (
+	"flag"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"tweetdeleter/internal"
+	"tweetdeleter/internal/fakex"
+)
+
+var record = flag.Bool("record", false, "record "+purgeRecording+" again by purging the fake site in chrome")
+
+const (
+	// purgeRecording is a purge of two tweets from the fake site in headless chrome
+	purgeRecording = "../fixtures/recordings/purge.json"
+	// recordedAddr is where the fake site was served while recording. Replays use it as the base URL so
+	// that the commands sent match the recorded ones, but nothing listens on it.
+	recordedAddr = "127.0.0.1:8931"
+)
+
+// purgeOptions are the options the recording was made with, apart from how the browser is driven
+func purgeOptions(logger *zap.Logger) internal.TweetDeleterOptions {
+	return internal.TweetDeleterOptions{
+		Username:  "fake",
+		Password:  "secret",
+		StartDate: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2023, 1, 8, 0, 0, 0, 0, time.UTC),
+		Logger:    logger,
+		BaseURL:   "http://" + recordedAddr,
+	}
+}
+
+// recordPurge writes purgeRecording by purging the fake site in headless chrome
+func recordPurge(t *testing.T) {
+	if _, err := internal.FindChrome(); err != nil {
+		t.Fatal(err)
+	}
+	opts := purgeOptions(zap.NewNop())
+	site := fakex.NewSite(fakex.Options{
+		Username: opts.Username,
+		Password: opts.Password,
+		Tweets:   fakex.GenerateTweets(2, opts.StartDate, opts.EndDate),
+	})
+	listener, err := net.Listen("tcp", recordedAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(site)
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	opts.Headless, opts.Record = true, purgeRecording
+	td, err := internal.NewTweetDeleter(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := td.Run(); err != nil {
+		t.Fatalf("could not record a purge: %v", err)
+	}
+	if left := site.Tweets(); len(left) != 0 {
+		t.Fatalf("recorded a purge that left %d tweets", len(left))
+	}
+}
+
+// The delete loop replays the recorded purge the same way without chrome or the site. Run with
+// -record to record it again after changing what the loop sends to the browser.
+func TestRunReplaysRecordedPurge(t *testing.T) {
+	if *record {
+		recordPurge(t)
+	}
+
+	driver, err := internal.NewReplayDriver(purgeRecording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	core, logs := observer.New(zap.InfoLevel)
+	opts := purgeOptions(zap.New(core))
+	opts.Driver = driver
+	td, err := internal.NewTweetDeleter(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := td.Run(); err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+	checkReportedCount(t, logs, 2)
+}
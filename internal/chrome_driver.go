@@ -0,0 +1,425 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/chromedp/cdproto"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/inspector"
+	cdplog "github.com/chromedp/cdproto/log"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// chromeOptions configure how chrome is launched
+type chromeOptions struct {
+	userDataDir string
+	headless    bool
+	// remoteURL, if set, is the websocket URL of an already running browser to connect to instead
+	// of launching chrome
+	remoteURL string
+	// recorder, if set, records the CDP traffic and DOM snapshots of every page
+	recorder *cdpRecorder
+	// loadMedia lets pages load the images, video, fonts and analytics that are otherwise blocked
+	loadMedia bool
+}
+
+// blockedRequests are the requests that are failed before they are sent unless chromeOptions.loadMedia
+// is set. None of them are needed to find and delete tweets, and search results full of images and
+// video are a large part of what makes each window slow to load.
+var blockedRequests = []*fetch.RequestPattern{
+	{URLPattern: "*", ResourceType: network.ResourceTypeImage},
+	{URLPattern: "*", ResourceType: network.ResourceTypeMedia},
+	{URLPattern: "*", ResourceType: network.ResourceTypeFont},
+	// X's client event logging
+	{URLPattern: "*/1.1/jot/*"},
+	{URLPattern: "*://*.google-analytics.com/*"},
+	{URLPattern: "*://*.googletagmanager.com/*"},
+	{URLPattern: "*://*.doubleclick.net/*"},
+	{URLPattern: "*://*.ads-twitter.com/*"},
+	{URLPattern: "*://analytics.twitter.com/*"},
+}
+
+// navigationRetryDelay is how long to wait before polling again when the page navigated during a poll
+const navigationRetryDelay = 100 * time.Millisecond
+
+// chromeDriver launches chrome using chromedp
+type chromeDriver struct {
+	opts chromeOptions
+}
+
+// chromePage is a chrome tab driven by chromedp
+type chromePage struct {
+	ctx         context.Context
+	cancel      context.CancelFunc
+	cancelAlloc context.CancelFunc
+	crashed     atomic.Bool
+	recording   *cdpSessionRecorder
+}
+
+// NewPage launches chrome and opens the tab that will be used to delete tweets
+func (d *chromeDriver) NewPage() (Page, error) {
+	var allocCtx context.Context
+	var cancelAlloc context.CancelFunc
+	if d.opts.remoteURL != "" {
+		allocCtx, cancelAlloc = chromedp.NewRemoteAllocator(context.Background(), d.opts.remoteURL, chromedp.NoModifyURL)
+	} else {
+		opts := append(chromedp.DefaultExecAllocatorOptions[:],
+			chromedp.Flag("headless", d.opts.headless),
+			chromedp.Flag("auto-open-devtools-for-tabs", false))
+		if d.opts.userDataDir != "" {
+			opts = append(opts, chromedp.UserDataDir(d.opts.userDataDir))
+		}
+		allocCtx, cancelAlloc = chromedp.NewExecAllocator(context.Background(), opts...)
+	}
+
+	var recording *cdpSessionRecorder
+	if d.opts.recorder != nil {
+		// chromedp hands every message sent to and received from chrome to the browser's debug logger.
+		// chromedp opens the first tab of a browser it launched differently to a tab of a browser it
+		// connected to, so the browser is started first and the page is opened in a new tab the same
+		// way the replay driver's is.
+		recording = d.opts.recorder.newSession()
+		browserCtx, cancelBrowser := chromedp.NewContext(allocCtx, chromedp.WithLogf(log.Printf),
+			chromedp.WithBrowserOption(chromedp.WithBrowserDebugf(recording.logf)))
+		if err := chromedp.Run(browserCtx); err != nil {
+			cancelBrowser()
+			cancelAlloc()
+			return nil, fmt.Errorf("error while starting chrome: %w", err)
+		}
+		stopChrome := cancelAlloc
+		allocCtx, cancelAlloc = browserCtx, func() {
+			cancelBrowser()
+			stopChrome()
+		}
+	}
+
+	// The browser's loggers can only be set by the context that starts it, which was done above when recording
+	var tabOpts []chromedp.ContextOption
+	if recording == nil {
+		tabOpts = append(tabOpts, chromedp.WithLogf(log.Printf))
+	}
+	ctx, cancel := chromedp.NewContext(allocCtx, tabOpts...)
+	p := &chromePage{ctx: ctx, cancel: cancel, cancelAlloc: cancelAlloc, recording: recording}
+
+	// chromedp cancels the context itself if it loses its connection to chrome, but a crashed
+	// or closed tab has to be detected from the tab's events
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch ev.(type) {
+		case *inspector.EventTargetCrashed, *inspector.EventDetached:
+			p.crashed.Store(true)
+			go p.cancel() // cancelling talks to the browser so it can't happen inside the listener
+		}
+	})
+
+	// The browser is started without a timeout since the context of the first run controls
+	// the lifetime of the browser
+	if err := chromedp.Run(ctx); err != nil {
+		p.Close()
+		return nil, fmt.Errorf("error while starting chrome: %w", err)
+	}
+	if !d.opts.loadMedia {
+		if err := p.blockRequests(); err != nil {
+			p.Close()
+			return nil, fmt.Errorf("could not block media requests: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// blockRequests fails the requests matching blockedRequests as chrome is about to send them
+func (p *chromePage) blockRequests() error {
+	chromedp.ListenTarget(p.ctx, func(ev interface{}) {
+		if ev, ok := ev.(*fetch.EventRequestPaused); ok {
+			// Only blocked requests are paused. Replying talks to the browser so it can't happen inside the listener.
+			go func() {
+				_ = chromedp.Run(p.ctx, fetch.FailRequest(ev.RequestID, network.ErrorReasonBlockedByClient))
+			}()
+		}
+	})
+	return chromedp.Run(p.ctx, fetch.Enable().WithPatterns(blockedRequests))
+}
+
+// run runs actions against the tab. The tab's context has to be used to talk to chrome, so
+// ctx's deadline and cancellation are applied to a context derived from it.
+func (p *chromePage) run(ctx context.Context, actions ...chromedp.Action) error {
+	runCtx, cancel := context.WithCancel(p.ctx)
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancelDeadline context.CancelFunc
+		runCtx, cancelDeadline = context.WithDeadline(runCtx, deadline)
+		defer cancelDeadline()
+	}
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	err := chromedp.Run(runCtx, actions...)
+	if errors.Is(err, chromedp.ErrPollingTimeout) {
+		return ErrWaitTimeout
+	}
+	return err
+}
+
+// Navigate loads url and waits for it to load
+func (p *chromePage) Navigate(ctx context.Context, url string) error {
+	err := p.run(ctx, chromedp.Navigate(url))
+	p.snapshot(ctx, "navigate "+url)
+	return err
+}
+
+// Location returns the current URL
+func (p *chromePage) Location(ctx context.Context) (string, error) {
+	var url string
+	err := p.run(ctx, chromedp.Location(&url))
+	return url, err
+}
+
+// Find waits up to timeout for the UI element called name to appear and marks the first match so
+// the other methods can act on it using located(name)
+func (p *chromePage) Find(ctx context.Context, name string, locators Locators, scope string, timeout time.Duration) (int, error) {
+	var found struct {
+		Index int `json:"index"`
+	}
+	err := p.poll(ctx, chromedp.PollFunction(withLocate(`(locators, scope, attr, name) => {
+		const found = locate(locators, scope);
+		if (!found) {
+			return false;
+		}
+		document.querySelectorAll("[" + attr + '="' + CSS.escape(name) + '"]').forEach((el) => el.removeAttribute(attr));
+		found.elements[0].setAttribute(attr, name);
+		return { index: found.index };
+	}`), &found,
+		chromedp.WithPollingMutation(),
+		chromedp.WithPollingTimeout(timeout),
+		chromedp.WithPollingArgs(locators, scope, locatedAttr, name),
+	))
+	if err != nil {
+		return 0, fmt.Errorf("could not find %s: %w", name, err)
+	}
+	return found.Index, nil
+}
+
+// Click clicks the element called name
+func (p *chromePage) Click(ctx context.Context, name string) error {
+	err := p.run(ctx, chromedp.Click(located(name), chromedp.NodeVisible))
+	p.snapshot(ctx, "click "+name)
+	return err
+}
+
+// Type types text into the element called name
+func (p *chromePage) Type(ctx context.Context, name, text string) error {
+	return p.run(ctx, chromedp.SendKeys(located(name), text))
+}
+
+// PressKey sends key to the page
+func (p *chromePage) PressKey(ctx context.Context, key string) error {
+	err := p.run(ctx, chromedp.KeyEvent(key))
+	p.snapshot(ctx, fmt.Sprintf("press %q", key))
+	return err
+}
+
+// WaitVisible waits until the element called name is visible
+func (p *chromePage) WaitVisible(ctx context.Context, name string) error {
+	return p.run(ctx, chromedp.WaitVisible(located(name)))
+}
+
+// UploadFiles sets the files chosen in the file input called name. File inputs are usually hidden
+// behind a button, so it doesn't wait for the input to be visible.
+func (p *chromePage) UploadFiles(ctx context.Context, name string, paths []string) error {
+	err := p.run(ctx, chromedp.SetUploadFiles(located(name), paths, chromedp.NodeReady))
+	p.snapshot(ctx, "upload to "+name)
+	return err
+}
+
+// Evaluate calls the script with args and stores its result in res
+func (p *chromePage) Evaluate(ctx context.Context, script Script, res interface{}, args ...interface{}) error {
+	return p.run(ctx, evaluateFunction(script.Source, res, args...))
+}
+
+// Poll calls the script with args until it returns a truthy value, which is stored in res
+func (p *chromePage) Poll(ctx context.Context, script Script, res interface{}, opts PollOptions, args ...interface{}) error {
+	pollOpts := []chromedp.PollOption{
+		chromedp.WithPollingTimeout(opts.Timeout),
+		chromedp.WithPollingArgs(args...),
+	}
+	if opts.Mutation {
+		pollOpts = append(pollOpts, chromedp.WithPollingMutation())
+	}
+	return p.poll(ctx, chromedp.PollFunction(script.Source, res, pollOpts...))
+}
+
+// poll runs a polling action, starting it again if the page navigates while it's waiting, since
+// chrome abandons the scripts running in the page that was left
+func (p *chromePage) poll(ctx context.Context, action chromedp.Action) error {
+	for {
+		err := p.run(ctx, action)
+		if !navigatedAway(err) || ctx.Err() != nil {
+			return err
+		}
+		// Give chromedp a moment to see the new page's execution context, which it reports in events
+		// that can arrive after the error
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(navigationRetryDelay):
+		}
+	}
+}
+
+// navigatedAway reports whether err is chrome failing a script because the page it was running in
+// was replaced by a navigation
+func navigatedAway(err error) bool {
+	var cdpErr *cdproto.Error
+	return errors.As(err, &cdpErr) &&
+		(strings.Contains(cdpErr.Message, "Execution context was destroyed") ||
+			strings.Contains(cdpErr.Message, "Cannot find context with specified id"))
+}
+
+// HTML returns the page's current HTML
+func (p *chromePage) HTML(ctx context.Context) (string, error) {
+	var html string
+	err := p.run(ctx, chromedp.OuterHTML("html", &html, chromedp.ByQuery))
+	return html, err
+}
+
+// Screenshot returns a JPEG screenshot of the whole page
+func (p *chromePage) Screenshot(ctx context.Context) ([]byte, error) {
+	var screenshot []byte
+	err := p.run(ctx, chromedp.FullScreenshot(&screenshot, 90))
+	return screenshot, err
+}
+
+// Cookies returns the browser's cookies for url
+func (p *chromePage) Cookies(ctx context.Context, url string) ([]Cookie, error) {
+	var cookies []*network.Cookie
+	err := p.run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		cookies, err = network.GetCookies().WithUrls([]string{url}).Do(ctx)
+		return err
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Cookie, 0, len(cookies))
+	for _, c := range cookies {
+		cookie := Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HTTPOnly: c.HTTPOnly,
+			SameSite: string(c.SameSite),
+		}
+		if !c.Session {
+			cookie.Expires = time.Unix(int64(c.Expires), 0)
+		}
+		res = append(res, cookie)
+	}
+	return res, nil
+}
+
+// SetCookie stores cookie in the browser
+func (p *chromePage) SetCookie(ctx context.Context, cookie Cookie) error {
+	set := network.SetCookie(cookie.Name, cookie.Value).
+		WithDomain(cookie.Domain).
+		WithPath(cookie.Path).
+		WithSecure(cookie.Secure).
+		WithHTTPOnly(cookie.HTTPOnly)
+	if cookie.SameSite != "" {
+		set = set.WithSameSite(network.CookieSameSite(cookie.SameSite))
+	}
+	if !cookie.Expires.IsZero() {
+		expires := cdp.TimeSinceEpoch(cookie.Expires)
+		set = set.WithExpires(&expires)
+	}
+	return p.run(ctx, set)
+}
+
+// Listen calls fn with the tab's events
+func (p *chromePage) Listen(fn func(ev interface{})) {
+	chromedp.ListenTarget(p.ctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *network.EventRequestWillBeSent:
+			fn(&RequestEvent{ID: string(ev.RequestID), Method: ev.Request.Method, URL: ev.Request.URL, Headers: canonicalHeaders(ev.Request.Headers)})
+		case *network.EventResponseReceived:
+			if ev.Response == nil {
+				return
+			}
+			fn(&ResponseEvent{ID: string(ev.RequestID), URL: ev.Response.URL, Status: int(ev.Response.Status), Headers: canonicalHeaders(ev.Response.Headers)})
+		case *network.EventLoadingFailed:
+			fn(&RequestFailedEvent{ID: string(ev.RequestID), Error: ev.ErrorText})
+		case *runtime.EventConsoleAPICalled:
+			args := make([]string, 0, len(ev.Args))
+			for _, arg := range ev.Args {
+				args = append(args, remoteObjectString(arg))
+			}
+			fn(&ConsoleEvent{Text: fmt.Sprintf("console.%s: %s", ev.Type, strings.Join(args, " "))})
+		case *runtime.EventExceptionThrown:
+			fn(&ConsoleEvent{Text: "exception: " + ev.ExceptionDetails.Error()})
+		case *cdplog.EventEntryAdded:
+			fn(&ConsoleEvent{Text: fmt.Sprintf("%s %s: %s %s", ev.Entry.Source, ev.Entry.Level, ev.Entry.Text, ev.Entry.URL)})
+		case *inspector.EventTargetCrashed, *inspector.EventDetached:
+			fn(&CrashEvent{})
+		}
+	})
+}
+
+// Lost reports whether chrome or its tab has gone away
+func (p *chromePage) Lost() bool {
+	return p.crashed.Load() || p.ctx.Err() != nil
+}
+
+// Close shuts down chrome
+func (p *chromePage) Close() {
+	p.cancel()
+	p.cancelAlloc()
+	if p.recording != nil {
+		if err := p.recording.save(); err != nil {
+			log.Printf("could not save the CDP recording: %v", err)
+		}
+	}
+}
+
+// snapshot records the page's DOM after the action described by after, if the page is being recorded
+func (p *chromePage) snapshot(ctx context.Context, after string) {
+	if p.recording == nil {
+		return
+	}
+	var url, html string
+	if err := p.run(ctx, chromedp.Location(&url), chromedp.OuterHTML("html", &html, chromedp.ByQuery)); err != nil {
+		return
+	}
+	p.recording.snapshot(after, url, html)
+}
+
+// canonicalHeaders converts CDP headers to a map keyed by canonical header names
+func canonicalHeaders(h network.Headers) map[string]string {
+	headers := make(map[string]string, len(h))
+	for k, v := range h {
+		headers[http.CanonicalHeaderKey(k)] = fmt.Sprint(v)
+	}
+	return headers
+}
+
+// remoteObjectString renders a console argument the way devtools would print it
+func remoteObjectString(obj *runtime.RemoteObject) string {
+	switch {
+	case obj.Value != nil:
+		return string(obj.Value)
+	case obj.UnserializableValue != "":
+		return string(obj.UnserializableValue)
+	default:
+		return obj.Description
+	}
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+)
+
+// composeReadyScript implements ScriptComposeReady
+var composeReadyScript = Script{Name: ScriptComposeReady, Source: withLocate(`(button) => {
+	const found = locate(button, "");
+	if (!found) {
+		return false;
+	}
+	const el = found.elements[0];
+	if (el.disabled || el.getAttribute("aria-disabled") === "true") {
+		return false;
+	}
+	return { index: found.index };
+}`)}
+
+// postSentScript implements ScriptPostSent. X also shows a toast once a post has been sent, so the
+// composer closing is checked before looking for an error.
+var postSentScript = Script{Name: ScriptPostSent, Source: withLocate(`(textArea, error) => {
+	if (locate(textArea, "") === null) {
+		return { state: "sent", message: "" };
+	}
+	const shown = locate(error, "");
+	if (shown) {
+		return { state: "error", message: shown.elements[0].innerText };
+	}
+	return false;
+}`)}
+
+// composePage is the composer X opens to post a tweet
+type composePage struct {
+	screen
+	site site
+}
+
+// composePage returns the composer of the site tweets are restored to
+func (t *TweetDeleter) composePage() composePage {
+	return composePage{screen: t.screen, site: t.site}
+}
+
+// open opens the composer and waits for its text area
+func (c composePage) open() step {
+	return steps(
+		navigate(c.site.composeURL()),
+		c.locate("compose.textArea", c.selectors.Compose.TextArea, ""),
+	)
+}
+
+// write types text into the composer
+func (c composePage) write(text string) step {
+	return c.sendKeys("compose.textArea", c.selectors.Compose.TextArea, text)
+}
+
+// attach chooses the files at paths in the composer's media input, which starts uploading them
+func (c composePage) attach(paths []string) step {
+	return steps(
+		c.locate("compose.mediaInput", c.selectors.Compose.MediaInput, ""),
+		func(ctx context.Context, p Page) error {
+			return p.UploadFiles(ctx, "compose.mediaInput", paths)
+		},
+	)
+}
+
+// post waits for the post button to be enabled, which X holds off on until attached media have
+// finished uploading, and clicks it
+func (c composePage) post() step {
+	return steps(
+		func(ctx context.Context, p Page) error {
+			var ready struct {
+				Index int `json:"index"`
+			}
+			err := p.Poll(ctx, composeReadyScript, &ready, PollOptions{Mutation: true, Timeout: stepTimeout},
+				c.selectors.Compose.PostButton)
+			if err != nil {
+				return fmt.Errorf("post button was never enabled: %w", err)
+			}
+			c.logFallback("compose.postButton", c.selectors.Compose.PostButton, ready.Index)
+			return nil
+		},
+		c.click("compose.postButton", c.selectors.Compose.PostButton),
+	)
+}
+
+// waitSent blocks until the composer has closed after posting, failing with the error X shows if it
+// refused the post
+func (c composePage) waitSent() step {
+	return func(ctx context.Context, p Page) error {
+		var sent struct {
+			State   string `json:"state"`
+			Message string `json:"message"`
+		}
+		err := p.Poll(ctx, postSentScript, &sent, PollOptions{Mutation: true, Timeout: pageReadyTimeout},
+			c.selectors.Compose.TextArea, c.selectors.Compose.Error)
+		if err != nil {
+			return fmt.Errorf("composer never closed after posting: %w", err)
+		}
+		if sent.State == "error" {
+			return fmt.Errorf("x refused the post: %s", sent.Message)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,168 @@
+package internal
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxRecordedEvents is how many console and network events are kept for debug bundles
+	maxRecordedEvents = 500
+	// debugCaptureTimeout bounds how long capturing the state of the page may take
+	debugCaptureTimeout = 30 * time.Second
+	// minSecretLen is the shortest cookie value treated as a secret. Shorter ones, like a language
+	// preference, wouldn't let anyone use the session and would mangle the bundle if they were redacted.
+	minSecretLen = 16
+	// redacted replaces the secrets left out of debug bundles
+	redacted = "[redacted]"
+)
+
+// secretParams matches the URL query parameters that carry credentials, such as OAuth codes and tokens
+var secretParams = regexp.MustCompile(`(?i)([?&](?:access_token|refresh_token|oauth_token|token|code|code_verifier|csrf_token|auth_token|ct0)=)[^&#\s"']*`)
+
+// eventRecorder keeps the most recent console and network events seen by a browser tab
+// so they can be included in debug bundles
+type eventRecorder struct {
+	mu      sync.Mutex
+	console []string
+	network []string
+}
+
+// listen starts recording events from page
+func (r *eventRecorder) listen(page Page) {
+	page.Listen(func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *ConsoleEvent:
+			r.record(&r.console, "%s", ev.Text)
+		case *RequestEvent:
+			r.record(&r.network, "request %s %s %s", ev.ID, ev.Method, ev.URL)
+		case *ResponseEvent:
+			r.record(&r.network, "response %s %d %s", ev.ID, ev.Status, ev.URL)
+		case *RequestFailedEvent:
+			r.record(&r.network, "failed %s %s", ev.ID, ev.Error)
+		}
+	})
+}
+
+// record appends a formatted, timestamped line to events, dropping the oldest line once full
+func (r *eventRecorder) record(events *[]string, format string, args ...interface{}) {
+	line := time.Now().Format(time.RFC3339Nano) + " " + fmt.Sprintf(format, args...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(*events) == maxRecordedEvents {
+		*events = (*events)[1:]
+	}
+	*events = append(*events, line)
+}
+
+// snapshot returns copies of the recorded console and network events
+func (r *eventRecorder) snapshot() (console, network []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.console...), append([]string(nil), r.network...)
+}
+
+// captureDebugBundle writes a screenshot, the current URL, the page's HTML and the recent
+// console and network events to a timestamped zip file in the debug directory. The password, the
+// session's cookies and credentials in URLs are redacted from everything but the screenshot. The
+// returned error is err annotated with the path of the bundle. If no debug directory is configured
+// or the bundle could not be written, err is returned as is.
+func (t *TweetDeleter) captureDebugBundle(s *browserSession, err error) error {
+	if t.debugDir == "" {
+		return err
+	}
+
+	secrets := []string{t.password}
+	consoleEvents, networkEvents := s.recorder.snapshot()
+	text := map[string]string{
+		"error.txt":   err.Error() + "\n",
+		"console.log": strings.Join(consoleEvents, "\n"),
+		"network.log": strings.Join(networkEvents, "\n"),
+	}
+	files := make(map[string][]byte)
+
+	// The page can't be captured if the browser is gone, but the events are still useful
+	if !s.lost() {
+		ctx, cancel := context.WithTimeout(s.ctx, debugCaptureTimeout)
+		defer cancel()
+
+		if cookies, captureErr := s.page.Cookies(ctx, t.site.baseURL); captureErr == nil {
+			for _, cookie := range cookies {
+				if len(cookie.Value) >= minSecretLen {
+					secrets = append(secrets, cookie.Value)
+				}
+			}
+		}
+		if url, captureErr := s.page.Location(ctx); captureErr == nil {
+			text["url.txt"] = url + "\n"
+		}
+		if html, captureErr := s.page.HTML(ctx); captureErr == nil {
+			text["page.html"] = html
+		}
+		if screenshot, captureErr := s.page.Screenshot(ctx); captureErr == nil {
+			files["screenshot.jpg"] = screenshot
+		}
+	}
+	for name, contents := range text {
+		files[name] = []byte(redact(contents, secrets))
+	}
+
+	path, writeErr := writeDebugBundle(t.debugDir, files)
+	if writeErr != nil {
+		t.logger.Warn("could not write debug bundle", zap.Error(writeErr))
+		return err
+	}
+	t.logger.Info("wrote debug bundle", zap.String("path", path))
+	return fmt.Errorf("%w (debug bundle: %s)", err, path)
+}
+
+// redact replaces the secrets and the credentials in URLs found in text
+func redact(text string, secrets []string) string {
+	text = secretParams.ReplaceAllString(text, "${1}"+redacted)
+	for _, secret := range secrets {
+		if secret != "" {
+			text = strings.ReplaceAll(text, secret, redacted)
+		}
+	}
+	return text
+}
+
+// writeDebugBundle zips files into a new timestamped file in dir and returns its path
+func writeDebugBundle(dir string, files map[string][]byte) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("tweetdeleter-debug-%s.zip", time.Now().Format("20060102T150405.000"))
+	path := filepath.Join(dir, name)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for file, contents := range files {
+		w, err := zw.Create(file)
+		if err != nil {
+			return "", err
+		}
+		if _, err := w.Write(contents); err != nil {
+			return "", err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return path, f.Close()
+}
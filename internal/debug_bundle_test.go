@@ -0,0 +1,17 @@
+package internal
+
+import "testing"
+
+// Credentials in URLs, the password and the session's cookies are left out of debug bundles
+func TestRedact(t *testing.T) {
+	const session = "0123456789abcdef0123456789abcdef"
+	text := `request 1 GET https://x.com/i/oauth2/authorize?code=abc123&state=xyz
+<script>document.cookie = "ct0=` + session + `"</script>
+login failed for hunter2`
+	want := `request 1 GET https://x.com/i/oauth2/authorize?code=[redacted]&state=xyz
+<script>document.cookie = "ct0=[redacted]"</script>
+login failed for [redacted]`
+	if got := redact(text, []string{"hunter2", session}); got != want {
+		t.Errorf("redact() = %q, want %q", got, want)
+	}
+}
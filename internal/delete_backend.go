@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+)
+
+// DeleteMode is how tweets are deleted once they've been found
+type DeleteMode string
+
+// Delete modes
+const (
+	// DeleteModeUI clicks through each tweet's "More" menu and confirmation sheet, the way a person would
+	DeleteModeUI DeleteMode = "ui"
+	// DeleteModeHTTP calls X's GraphQL API directly with the browser's session, which is faster and
+	// doesn't depend on the menu's selectors
+	DeleteModeHTTP DeleteMode = "http"
+	// DeleteModeBatch runs a script in the page that calls X's GraphQL API for a batch of the tweets
+	// shown at a time, avoiding a round trip between Go and the page for every click
+	DeleteModeBatch DeleteMode = "batch"
+	// DeleteModeAPI uses the official X API v2 instead of chrome, authorized by the account owner
+	// through OAuth 2.0. It needs an X app with API access.
+	DeleteModeAPI DeleteMode = "api"
+)
+
+// deleteBackend deletes the tweets collected from the search results
+type deleteBackend interface {
+	// listen starts watching the events of a newly launched page
+	listen(page Page)
+	// deleteTweet deletes the collected tweet with id, or more of the collected tweets starting with it
+	// and continuing with queued, which are the tweets collected after it. It returns the ids of the
+	// tweets it deleted, even if it fails part way.
+	deleteTweet(s *browserSession, search searchPage, id string, queued []string) ([]string, error)
+}
+
+// newDeleteBackend returns the backend for mode, recording throttled API calls in rateLimit
+func newDeleteBackend(mode DeleteMode, site site, rateLimit *rateLimitMonitor) (deleteBackend, error) {
+	switch mode {
+	case "", DeleteModeUI:
+		return uiBackend{}, nil
+	case DeleteModeHTTP:
+		return &graphqlBackend{client: newGraphQLClient(site, rateLimit)}, nil
+	case DeleteModeBatch:
+		return &batchBackend{client: newGraphQLClient(site, rateLimit)}, nil
+	}
+	return nil, fmt.Errorf("unknown delete mode %q. expected %q, %q, %q or %q",
+		mode, DeleteModeUI, DeleteModeHTTP, DeleteModeBatch, DeleteModeAPI)
+}
+
+// uiBackend deletes tweets through the page's UI
+type uiBackend struct{}
+
+func (uiBackend) listen(page Page) {}
+
+func (uiBackend) deleteTweet(s *browserSession, search searchPage, id string, queued []string) ([]string, error) {
+	tweet := search.collectedTweet(id)
+	if err := runStep(s.ctx, s.page, tweet.markCollected(), tweet.delete()); err != nil {
+		return nil, err
+	}
+	return []string{id}, nil
+}
+
+// graphqlBackend deletes tweets by calling X's DeleteTweet mutation and then removes them from the
+// page
+type graphqlBackend struct {
+	client *graphqlClient
+}
+
+func (b *graphqlBackend) listen(page Page) {
+	b.client.listen(page)
+}
+
+func (b *graphqlBackend) deleteTweet(s *browserSession, search searchPage, id string, queued []string) ([]string, error) {
+	tweet := search.collectedTweet(id)
+	if err := runStep(s.ctx, s.page, tweet.markCollected()); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, stepTimeout)
+	defer cancel()
+	if err := b.client.deleteTweet(ctx, s.page, id); err != nil {
+		return nil, fmt.Errorf("could not delete tweet %s: %w", id, err)
+	}
+	return []string{id}, runStep(s.ctx, s.page, tweet.remove())
+}
@@ -0,0 +1,311 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// doctorCheckTimeout is how long the doctor waits for each element before failing its check
+const doctorCheckTimeout = 10 * time.Second
+
+// CheckStatus is the outcome of a single doctor check
+type CheckStatus string
+
+// Doctor check outcomes
+const (
+	CheckPassed  CheckStatus = "PASS"
+	CheckFailed  CheckStatus = "FAIL"
+	CheckSkipped CheckStatus = "SKIP"
+)
+
+// CheckResult is the outcome of a single doctor check along with details explaining it
+type CheckResult struct {
+	Name   string
+	Status CheckStatus
+	Detail string
+}
+
+// DoctorOptions configure the optional checks run by Doctor
+type DoctorOptions struct {
+	// SearchSince and SearchUntil are a time range known to contain at least one tweet. If set,
+	// the doctor searches the range and checks the elements used to delete a tweet without
+	// deleting anything.
+	SearchSince time.Time
+	SearchUntil time.Time
+}
+
+// doctor accumulates the results of the checks run by Doctor
+type doctor struct {
+	t       *TweetDeleter
+	results []CheckResult
+}
+
+// pass records a check that passed
+func (d *doctor) pass(name, detail string) {
+	d.results = append(d.results, CheckResult{Name: name, Status: CheckPassed, Detail: detail})
+}
+
+// fail records a check that failed because of err
+func (d *doctor) fail(name string, err error) {
+	d.results = append(d.results, CheckResult{Name: name, Status: CheckFailed, Detail: err.Error()})
+}
+
+// skip records a check that could not be run
+func (d *doctor) skip(name, reason string) {
+	d.results = append(d.results, CheckResult{Name: name, Status: CheckSkipped, Detail: reason})
+}
+
+// element checks that the element called name can be found, reporting which locator found it
+func (d *doctor) element(s *browserSession, name string, locators Locators, scope string) bool {
+	index, err := s.page.Find(s.ctx, name, locators, scope, doctorCheckTimeout)
+	if err != nil {
+		d.fail("selector "+name, err)
+		return false
+	}
+
+	detail := "found by " + locators[index].String()
+	if index > 0 {
+		detail += " (fallback)"
+	}
+	d.pass("selector "+name, detail)
+	return true
+}
+
+// run runs steps against the browser, recording a failed check called name if they fail
+func (d *doctor) run(s *browserSession, name string, ss ...step) bool {
+	if err := runStep(s.ctx, s.page, ss...); err != nil {
+		d.fail(name, err)
+		return false
+	}
+	return true
+}
+
+// Doctor checks that the environment and selector profile are usable without deleting anything. It
+// checks that chrome can be found and launched, that the login page and the selectors used on it
+// resolve, whether the saved session is still valid and, if DoctorOptions provides a time range,
+// whether the profile labels a pinned tweet, that searching works and the selectors used to delete a
+// tweet resolve.
+func (t *TweetDeleter) Doctor(opts DoctorOptions) []CheckResult {
+	d := &doctor{t: t}
+
+	if _, ok := t.driver.(*chromeDriver); ok {
+		path, err := findChrome()
+		if err != nil {
+			d.fail("chrome installed", err)
+			return d.results
+		}
+		d.pass("chrome installed", path)
+	} else {
+		d.skip("chrome installed", "not using chrome")
+	}
+
+	s, err := t.newBrowserSession(context.Background())
+	if err != nil {
+		d.fail("browser launches", err)
+		return d.results
+	}
+	defer s.close()
+	d.pass("browser launches", "")
+
+	d.checkLoginPage(s)
+
+	if !d.checkSession(s) {
+		return d.results
+	}
+
+	if opts.SearchSince.IsZero() || opts.SearchUntil.IsZero() {
+		d.skip("search", "no search window provided")
+		return d.results
+	}
+	d.checkProfile(s)
+	d.checkSearch(s, opts.SearchSince, opts.SearchUntil)
+	return d.results
+}
+
+// checkLoginPage loads the login flow and checks the selectors used on it. If a username is
+// configured it is submitted so the password step can be checked too, but the password is not.
+func (d *doctor) checkLoginPage(s *browserSession) {
+	sel, login := d.t.selectors, d.t.loginPage()
+	if !d.run(s, "login page loads", login.open()) {
+		return
+	}
+	d.pass("login page loads", "")
+
+	if !d.element(s, "login.usernameInput", sel.Login.UsernameInput, "") ||
+		!d.element(s, "login.nextButton", sel.Login.NextButton, "") {
+		return
+	}
+	if d.t.username == "" {
+		d.skip("selector login.passwordInput", "no username provided")
+		d.skip("selector login.submitButton", "no username provided")
+		return
+	}
+
+	if !d.run(s, "submit username", login.enterUsername(d.t.username)) {
+		return
+	}
+	if d.element(s, "login.passwordInput", sel.Login.PasswordInput, "") {
+		d.element(s, "login.submitButton", sel.Login.SubmitButton, "")
+	}
+	d.skip("selector login.challengeInput", "only shown when x asks for verification")
+	d.skip("selector login.errorMessage", "only shown when logging in fails")
+}
+
+// checkSession checks whether the saved session is still logged in, logging in with the configured
+// credentials if it isn't. It reports whether the browser ended up logged in.
+func (d *doctor) checkSession(s *browserSession) bool {
+	switch {
+	case d.t.chrome.userDataDir == "":
+		d.skip("saved session valid", "no user data dir provided")
+	case d.t.loggedIn(s):
+		d.pass("saved session valid", d.t.chrome.userDataDir)
+		d.pass("selector session.loggedIn", "")
+		d.skip("selector session.loggedOut", "only shown when logged out")
+		return true
+	default:
+		d.fail("saved session valid", fmt.Errorf("%s is not logged in", d.t.chrome.userDataDir))
+	}
+
+	if d.t.username == "" || d.t.password == "" {
+		d.skip("login", "no username and password provided")
+		return false
+	}
+	if !d.run(s, "login", d.t.loginPage().logIn(d.t.username, d.t.password)) {
+		return false
+	}
+	d.pass("login", d.t.username)
+	d.pass("selector session.loggedIn", "")
+	d.skip("selector session.loggedOut", "only shown when logged out")
+	return true
+}
+
+// checkProfile opens the account's profile and checks whether its first tweet is labelled as pinned
+// and that its text can be read, which restoring tweets relies on.
+// It is only run when the account is known to have tweets, since otherwise there is nothing to check.
+func (d *doctor) checkProfile(s *browserSession) {
+	if d.t.username == "" {
+		d.skip("profile", "no username provided")
+		d.skip("selector tweet.pinnedLabel", "no username provided")
+		d.skip("selector tweet.text", "no username provided")
+		return
+	}
+	profile := d.t.profilePage()
+	tweet := profile.firstTweet()
+	var tweetID string
+	if !d.run(s, "profile", profile.open(d.t.username), tweet.mark(&tweetID)) {
+		return
+	}
+	d.pass("profile", d.t.username)
+
+	var pinned bool
+	if !d.run(s, "selector tweet.pinnedLabel", tweet.isPinned(&pinned)) {
+		return
+	}
+	if pinned {
+		d.pass("selector tweet.pinnedLabel", "tweet "+tweetID+" is pinned")
+	} else {
+		d.skip("selector tweet.pinnedLabel", "the account has no pinned tweet")
+	}
+
+	var text string
+	if !d.run(s, "selector tweet.text", tweet.text(&text)) {
+		return
+	}
+	if text != "" {
+		d.pass("selector tweet.text", "")
+	} else {
+		d.skip("selector tweet.text", "tweet "+tweetID+" has no text")
+	}
+}
+
+// checkSearch searches the provided window and checks the selectors used to find and delete a tweet.
+// The first tweet's menu is opened and then closed again without deleting anything.
+func (d *doctor) checkSearch(s *browserSession, since, until time.Time) {
+	sel, search := d.t.selectors, d.t.searchPage()
+	if !d.run(s, "search", search.searchTweets(d.t.username, since, until)) {
+		return
+	}
+
+	var result searchResult
+	if !d.run(s, "search results", search.waitForResults(&result)) {
+		return
+	}
+	d.pass("selector search.activeLatestTab", "")
+	if result != searchResultTweets {
+		d.fail("search results", fmt.Errorf("expected tweets between %s and %s but search returned %s",
+			since.Format(time.DateOnly), until.Format(time.DateOnly), result))
+		return
+	}
+	d.pass("search results", "")
+	d.skip("selector search.emptyState", "search returned tweets")
+	d.skip("selector search.errorBanner", "only shown when x fails to load results")
+
+	var tweetID string
+	tweet := search.firstTweet()
+	if !d.run(s, "selector tweet.article", tweet.mark(&tweetID)) {
+		return
+	}
+	d.pass("selector tweet.article", "")
+	if tweetID == "" {
+		d.fail("selector tweet.permalink", fmt.Errorf("could not find the first tweet's id"))
+	} else {
+		d.pass("selector tweet.permalink", "tweet "+tweetID)
+	}
+
+	if !d.element(s, "tweet.moreButton", sel.Tweet.MoreButton, tweet.scope()) ||
+		!d.run(s, "open tweet menu", clickLocated("tweet.moreButton"), tweet.menu().waitOpen()) {
+		return
+	}
+	d.pass("selector tweet.menu", "")
+	d.element(s, "tweet.menuItem", sel.Tweet.MenuItem, "")
+	d.element(s, "tweet.deleteMenuItem", sel.Tweet.DeleteMenuItem, "")
+	d.skip("selector tweet.confirmButton", "only shown after clicking delete")
+
+	// Close the menu without choosing anything
+	d.run(s, "close tweet menu", tweet.menu().close())
+}
+
+// findChrome returns the path of the chrome executable chromedp will launch. These are the
+// same locations chromedp searches.
+func findChrome() (string, error) {
+	var locations []string
+	switch runtime.GOOS {
+	case "darwin":
+		locations = []string{
+			"/Applications/Chromium.app/Contents/MacOS/Chromium",
+			"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+		}
+	case "windows":
+		locations = []string{
+			"chrome",
+			"chrome.exe",
+			`C:\Program Files (x86)\Google\Chrome\Application\chrome.exe`,
+			`C:\Program Files\Google\Chrome\Application\chrome.exe`,
+		}
+	default:
+		locations = []string{
+			"headless_shell",
+			"headless-shell",
+			"chromium",
+			"chromium-browser",
+			"google-chrome",
+			"google-chrome-stable",
+			"google-chrome-beta",
+			"google-chrome-unstable",
+			"/usr/bin/google-chrome",
+			"/usr/local/bin/chrome",
+			"/snap/bin/chromium",
+			"chrome",
+		}
+	}
+
+	for _, location := range locations {
+		if path, err := exec.LookPath(location); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("could not find chrome or chromium. looked for %v", locations)
+}
@@ -0,0 +1,56 @@
+package internal_test
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+
+	"tweetdeleter/internal"
+	"tweetdeleter/internal/fakex"
+)
+
+// doctor runs the doctor against site in memory, searching runSince to runUntil, and returns the
+// results by check name
+func doctor(t *testing.T, site *fakex.Site) map[string]internal.CheckResult {
+	t.Helper()
+	td, err := internal.NewTweetDeleter(internal.TweetDeleterOptions{
+		Username: "fake",
+		Password: "secret",
+		Logger:   zap.NewNop(),
+		Driver:   fakex.NewDriver(site),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := make(map[string]internal.CheckResult)
+	for _, r := range td.Doctor(internal.DoctorOptions{SearchSince: runSince, SearchUntil: runUntil}) {
+		if r.Status == internal.CheckFailed {
+			t.Errorf("check %q failed: %s", r.Name, r.Detail)
+		}
+		results[r.Name] = r
+	}
+	return results
+}
+
+func TestDoctorChecksPinnedTweet(t *testing.T) {
+	tweets := fakex.GenerateTweets(5, runSince, runUntil)
+	// The oldest tweet is pinned above the newer ones
+	pinned := tweets[len(tweets)-1]
+	site := fakex.NewSite(fakex.Options{Username: "fake", Password: "secret", Tweets: tweets, Pinned: pinned.ID})
+
+	results := doctor(t, site)
+	if r := results["selector tweet.pinnedLabel"]; r.Status != internal.CheckPassed || r.Detail != "tweet "+pinned.ID+" is pinned" {
+		t.Errorf("pinned label check = %+v, want a pass for tweet %s", r, pinned.ID)
+	}
+	if left := site.Tweets(); len(left) != len(tweets) {
+		t.Errorf("%d tweets left, want all %d since the doctor doesn't delete anything", len(left), len(tweets))
+	}
+}
+
+func TestDoctorSkipsPinnedLabelWithoutPinnedTweet(t *testing.T) {
+	site := fakex.NewSite(fakex.Options{Username: "fake", Password: "secret", Tweets: fakex.GenerateTweets(5, runSince, runUntil)})
+
+	if r := doctor(t, site)["selector tweet.pinnedLabel"]; r.Status != internal.CheckSkipped {
+		t.Errorf("pinned label check = %+v, want it skipped", r)
+	}
+}
@@ -0,0 +1,233 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrWaitTimeout is returned by pages when an element or condition being waited on never appeared
+var ErrWaitTimeout = errors.New("timed out waiting on the page")
+
+// Driver launches the browser used to delete tweets
+type Driver interface {
+	// NewPage launches a browser and opens the page that tweets will be deleted from
+	NewPage() (Page, error)
+}
+
+// Page is a browser tab. TweetDeleter only drives the browser through a Page so that the deletion
+// logic doesn't depend on chrome and can be run against an in-memory fake.
+//
+// Elements are found by name using Find, after which the methods acting on an element refer to
+// the element most recently found with that name. The JavaScript TweetDeleter evaluates is passed
+// as a Script so that pages which can't run JavaScript can implement each script natively.
+type Page interface {
+	// Navigate loads url and waits for it to load
+	Navigate(ctx context.Context, url string) error
+	// Location returns the current URL
+	Location(ctx context.Context) (string, error)
+	// Find waits up to timeout for the UI element called name to appear, looking only inside the elements
+	// matching the CSS selector scope if it isn't empty, and returns the index of the locator that found it
+	Find(ctx context.Context, name string, locators Locators, scope string, timeout time.Duration) (int, error)
+	// Click clicks the element called name
+	Click(ctx context.Context, name string) error
+	// Type types text into the element called name. Keys from chromedp's kb package may be included.
+	Type(ctx context.Context, name, text string) error
+	// PressKey sends a single key from chromedp's kb package to the page
+	PressKey(ctx context.Context, key string) error
+	// WaitVisible waits until the element called name is visible
+	WaitVisible(ctx context.Context, name string) error
+	// UploadFiles sets the files chosen in the file input called name to the files at paths
+	UploadFiles(ctx context.Context, name string, paths []string) error
+	// Evaluate calls the script with args and stores its result in res
+	Evaluate(ctx context.Context, script Script, res interface{}, args ...interface{}) error
+	// Poll calls the script with args until it returns a truthy value, which is stored in res. It returns
+	// ErrWaitTimeout if that doesn't happen within the timeout in opts.
+	Poll(ctx context.Context, script Script, res interface{}, opts PollOptions, args ...interface{}) error
+	// HTML returns the page's current HTML
+	HTML(ctx context.Context) (string, error)
+	// Screenshot returns a JPEG screenshot of the whole page
+	Screenshot(ctx context.Context) ([]byte, error)
+	// Cookies returns the browser's cookies for url
+	Cookies(ctx context.Context, url string) ([]Cookie, error)
+	// SetCookie stores cookie in the browser
+	SetCookie(ctx context.Context, cookie Cookie) error
+	// Listen calls fn with every event the page emits. Events are one of *RequestEvent, *ResponseEvent,
+	// *RequestFailedEvent, *ConsoleEvent or *CrashEvent.
+	Listen(fn func(ev interface{}))
+	// Lost reports whether the browser or the page has gone away
+	Lost() bool
+	// Close shuts down the browser
+	Close()
+}
+
+// Script is a JavaScript function evaluated in the page. Name identifies it to pages that implement
+// it natively and is one of the Script* constants. Evaluate waits for scripts that return a promise
+// to settle.
+type Script struct {
+	Name   string
+	Source string
+}
+
+// Names of the scripts TweetDeleter evaluates, along with their arguments and results
+const (
+	// ScriptSearchResults takes the active latest tab, tweet article, empty state and error banner
+	// locators. Once the latest tab is active, or as soon as the error banner is shown, it returns
+	// {state, index} where state is "tweets", "empty" or "error" and index is the index of the locator
+	// that found the tweets, empty state or error banner.
+	ScriptSearchResults = "searchResults"
+	// ScriptCount takes the name of a UI element, its locators and a CSS scope. It returns the number of
+	// elements found by the first locator that matches anything inside the scope, or the whole page if
+	// the scope is empty.
+	ScriptCount = "count"
+	// ScriptMarkFirstTweet takes the tweet article and permalink locators and the attribute used to mark
+	// the tweet being deleted. It marks the first tweet and returns {id, index} where id is the tweet's
+	// id, if it could be found, and index is the index of the locator that found the tweet.
+	ScriptMarkFirstTweet = "markFirstTweet"
+	// ScriptMarkTweet takes the attribute ScriptCollectTweets marked tweets with, the id of a collected
+	// tweet and the attribute used to mark the tweet being deleted. It marks the collected tweet with the
+	// id and returns whether it is still on the page.
+	ScriptMarkTweet = "markTweet"
+	// ScriptDropdownReady takes the tweet menu and menu item locators and returns {index} once the menu
+	// is open and has finished animating, where index is the index of the locator that found the menu
+	ScriptDropdownReady = "dropdownReady"
+	// ScriptTweetDeleted takes the confirm button locators and the attribute marking the tweet being
+	// deleted. It returns true once the confirmation sheet has closed and the tweet is gone.
+	ScriptTweetDeleted = "tweetDeleted"
+	// ScriptLoggedOut takes the logged out locators and returns whether the page is logged out
+	ScriptLoggedOut = "loggedOut"
+	// ScriptLoginError takes the challenge input and login error locators and returns why logging in
+	// failed: "locked", "challenge", "failed" or "" if the page doesn't say
+	ScriptLoginError = "loginError"
+	// ScriptErrorBanner takes the error banner locators and returns whether X's "Something went wrong"
+	// error is shown
+	ScriptErrorBanner = "errorBanner"
+	// ScriptTweetText takes the tweet text locators and the CSS scope of the marked tweet. It returns
+	// {text, index} where text is the marked tweet's text and index is the index of the locator that
+	// found it, or -1 if the tweet has no text.
+	ScriptTweetText = "tweetText"
+	// ScriptRemoveMarked takes the attribute marking the tweet being acted on and removes the marked tweet
+	// from the page
+	ScriptRemoveMarked = "removeMarked"
+	// ScriptCollectTweets takes the tweet article and permalink locators, the attribute used to mark
+	// tweets and the maximum number of tweets to collect. It marks each of the first tweets with its id
+	// and returns {ids, index} where ids are the ids of the marked tweets and index is the index of the
+	// locator that found them.
+	ScriptCollectTweets = "collectTweets"
+	// ScriptBatchDelete takes the ids of tweets marked by ScriptCollectTweets, the marking attribute,
+	// the bearer token to send, the DeleteTweet query id and the delay between calls in milliseconds.
+	// It deletes the tweets one after the other by calling X's API from the page, removes the deleted
+	// tweets from the page and returns [{id, status, error}] for each tweet it tried to delete. It stops
+	// at the first tweet that couldn't be deleted.
+	ScriptBatchDelete = "batchDelete"
+	// ScriptComposeReady takes the post button locators and returns {index} once the composer's post
+	// button is enabled, which X holds off on until attached media have finished uploading. index is the
+	// index of the locator that found the button.
+	ScriptComposeReady = "composeReady"
+	// ScriptPostSent takes the composer's text area and error locators. It returns {state, message} once
+	// the composer has either closed, with state "sent", or shown an error, with state "error" and the
+	// error's text in message.
+	ScriptPostSent = "postSent"
+	// ScriptLoadFixture takes the HTML of a selector fixture and the attribute marking its expected
+	// elements. It replaces the page's document with the fixture and returns the names of the expected elements.
+	ScriptLoadFixture = "loadFixture"
+	// ScriptCheckFixture takes the name of a UI element, its locators and the attribute marking a fixture's
+	// expected elements. It returns {index, matched, missing, unexpected} where index is the index of the
+	// locator that matched, or -1, matched is the number of elements it matched, missing is the number of
+	// expected elements it didn't match and unexpected is the number of matched elements that weren't expected.
+	ScriptCheckFixture = "checkFixture"
+)
+
+// PollOptions configure how a page polls a script
+type PollOptions struct {
+	// Mutation re-evaluates the script on every DOM mutation. Otherwise it is evaluated on every
+	// animation frame, which is needed for conditions like animations finishing that don't mutate the DOM.
+	Mutation bool
+	// Timeout is how long to poll before giving up with ErrWaitTimeout
+	Timeout time.Duration
+}
+
+// Cookie is a browser cookie
+type Cookie struct {
+	Name     string
+	Value    string
+	Domain   string
+	Path     string
+	Expires  time.Time // zero for session cookies
+	Secure   bool
+	HTTPOnly bool
+	SameSite string
+}
+
+// RequestEvent is emitted when the page sends a request
+type RequestEvent struct {
+	ID      string
+	Method  string
+	URL     string
+	Headers map[string]string
+}
+
+// ResponseEvent is emitted when the page receives a response
+type ResponseEvent struct {
+	ID      string
+	URL     string
+	Status  int
+	Headers map[string]string
+}
+
+// RequestFailedEvent is emitted when a request sent by the page fails
+type RequestFailedEvent struct {
+	ID    string
+	Error string
+}
+
+// ConsoleEvent is emitted when the page logs to the console or throws an uncaught exception
+type ConsoleEvent struct {
+	Text string
+}
+
+// CrashEvent is emitted when the page crashes or is closed
+type CrashEvent struct{}
+
+// step is a single interaction with the page that can be run with runStep
+type step func(ctx context.Context, p Page) error
+
+// steps combines ss into a single step that runs them in order
+func steps(ss ...step) step {
+	return func(ctx context.Context, p Page) error {
+		for _, s := range ss {
+			if err := s(ctx, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// navigate is a step that loads url
+func navigate(url string) step {
+	return func(ctx context.Context, p Page) error {
+		return p.Navigate(ctx, url)
+	}
+}
+
+// sleep is a step that pauses for d
+func sleep(d time.Duration) step {
+	return func(ctx context.Context, p Page) error {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return nil
+		}
+	}
+}
+
+// evaluate is a step that calls script with args and stores its result in res
+func evaluate(script Script, res interface{}, args ...interface{}) step {
+	return func(ctx context.Context, p Page) error {
+		return p.Evaluate(ctx, script, res, args...)
+	}
+}
@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrLoginFailed indicates that X rejected the provided username or password, or Bluesky rejected
+	// the handle or app password
+	ErrLoginFailed = errors.New("login failed")
+	// ErrChallengeRequired indicates that X asked for additional verification while logging in,
+	// such as a confirmation code, phone number, email or captcha, or that Bluesky asked for a two factor code
+	ErrChallengeRequired = errors.New("login challenge required")
+	// ErrRateLimited indicates that X, or the other platform being deleted from, is throttling the account
+	ErrRateLimited = errors.New("rate limited")
+	// ErrSelectorMissing indicates that an element we expected never appeared on the page.
+	// This usually means X has changed its UI.
+	ErrSelectorMissing = errors.New("selector missing")
+	// ErrAccountLocked indicates that X or Bluesky has locked or suspended the account
+	ErrAccountLocked = errors.New("account locked")
+	// ErrPartialCompletion indicates that a run failed after it had already deleted some tweets or posts, or
+	// restored some tweets
+	ErrPartialCompletion = errors.New("partial completion")
+)
+
+// loginErrorScript implements ScriptLoginError
+var loginErrorScript = Script{Name: ScriptLoginError, Source: withLocate(`(challenge, failed) => {
+	if (location.pathname.startsWith("/account/access")) {
+		return "locked";
+	}
+	if (location.pathname.includes("login_challenge") ||
+		location.pathname.includes("login_verification") ||
+		locate(challenge, "") !== null ||
+		document.querySelector('iframe[src*="arkoselabs"]') !== null) {
+		return "challenge";
+	}
+	if (locate(failed, "") !== null) {
+		return "failed";
+	}
+	return "";
+}`)}
+
+// quickCheck returns a context for inspecting the page after a step failed. The page may have been
+// left in a broken state so it isn't waited on for long.
+func quickCheck(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, 5*time.Second)
+}
+
+// checkLoginError inspects the browser after logging in failed with err to determine why,
+// wrapping err with ErrAccountLocked, ErrChallengeRequired or ErrLoginFailed when the page
+// tells us what went wrong
+func (t *TweetDeleter) checkLoginError(s *browserSession, err error) error {
+	err = t.captureDebugBundle(s, err)
+	if s.lost() {
+		return fmt.Errorf("%w: %w", errSessionLost, err)
+	}
+
+	ctx, cancel := quickCheck(s.ctx)
+	defer cancel()
+
+	var state string
+	evalErr := t.loginPage().loginError(&state)(ctx, s.page)
+	if evalErr != nil {
+		return err
+	}
+
+	switch state {
+	case "locked":
+		return fmt.Errorf("%w: %w", ErrAccountLocked, err)
+	case "challenge":
+		return fmt.Errorf("%w: %w", ErrChallengeRequired, err)
+	case "failed":
+		return fmt.Errorf("%w: %w", ErrLoginFailed, err)
+	}
+	return err
+}
@@ -0,0 +1,4 @@
+package internal
+
+// FindChrome lets tests outside the package skip when chrome isn't installed
+var FindChrome = findChrome
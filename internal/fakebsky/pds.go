@@ -0,0 +1,466 @@
+// Package fakebsky is an offline stand-in for the XRPC endpoints of a Bluesky personal data server
+// (PDS) used to delete an account's posts, reposts and likes, and to copy tweets to it. It logs in
+// with an app password, lists the account's records and keeps track of which ones have been deleted,
+// so that BlueskyDeleter can be run end to end without a network.
+package fakebsky
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Collections served by the fake PDS
+const (
+	PostCollection   = "app.bsky.feed.post"
+	RepostCollection = "app.bsky.feed.repost"
+	LikeCollection   = "app.bsky.feed.like"
+)
+
+// defaultAccessLifetime is how long access tokens issued by the fake PDS are valid, like Bluesky's
+const defaultAccessLifetime = 2 * time.Hour
+
+// Record is a record in one of the account's collections
+type Record struct {
+	Collection string    `json:"collection"`
+	RKey       string    `json:"rkey"`
+	CreatedAt  time.Time `json:"createdAt"`
+	// Text is the text of a post. Reposts and likes don't have text.
+	Text string `json:"text,omitempty"`
+	// Images is the number of images embedded in a post
+	Images int `json:"images,omitempty"`
+}
+
+// Options configure a fake PDS
+type Options struct {
+	// Handle and AppPassword are the credentials of the PDS's only account
+	Handle      string
+	AppPassword string
+	// DID is the account's decentralized identifier. did:plc:fake is used if DID is empty.
+	DID string
+	// Records are the records in the account's collections. Records without an RKey are given one
+	// based on their creation time, the way Bluesky's clients do.
+	Records []Record
+	// AccessLifetime is how long access tokens are valid for. Set it to something short to check
+	// that sessions are refreshed. Two hours, like Bluesky's, is used if AccessLifetime is zero.
+	AccessLifetime time.Duration
+	// RateLimit throttles every RateLimit+1th call to deleteRecord or putRecord with a 429 response whose
+	// ratelimit-reset header is a second later, to check that throttled calls are retried. Calls
+	// aren't throttled if RateLimit is zero.
+	RateLimit int
+}
+
+// PDS is a fake Bluesky PDS. It implements http.Handler and is safe for concurrent use.
+type PDS struct {
+	handle         string
+	appPassword    string
+	did            string
+	accessLifetime time.Duration
+	rateLimit      int
+
+	mu      sync.Mutex
+	records map[string][]Record // records of each collection, newest record key first
+	deleted []string
+	access  map[string]time.Time // expiry of each access token
+	refresh map[string]bool
+	blobs   map[string]bool // cids of the uploaded blobs
+	writes  int
+}
+
+// NewPDS creates a fake PDS from opts
+func NewPDS(opts Options) *PDS {
+	did := opts.DID
+	if did == "" {
+		did = "did:plc:fake"
+	}
+	lifetime := opts.AccessLifetime
+	if lifetime == 0 {
+		lifetime = defaultAccessLifetime
+	}
+
+	records := make(map[string][]Record)
+	for _, record := range opts.Records {
+		if record.RKey == "" {
+			record.RKey = rkeyAt(record.CreatedAt)
+		}
+		records[record.Collection] = append(records[record.Collection], record)
+	}
+	for _, rs := range records {
+		sort.SliceStable(rs, func(i, j int) bool { return rs[i].RKey > rs[j].RKey })
+	}
+
+	return &PDS{
+		handle:         opts.Handle,
+		appPassword:    opts.AppPassword,
+		did:            did,
+		accessLifetime: lifetime,
+		rateLimit:      opts.RateLimit,
+		records:        records,
+		access:         make(map[string]time.Time),
+		refresh:        make(map[string]bool),
+		blobs:          make(map[string]bool),
+	}
+}
+
+// NewServer starts serving pds on a local port. The caller should call Close on the returned
+// server when finished and pass its URL to BlueskyDeleter as the PDS URL.
+func NewServer(pds *PDS) *httptest.Server {
+	return httptest.NewServer(pds)
+}
+
+// GenerateRecords returns n records in collection created evenly between since and until, newest first
+func GenerateRecords(collection string, n int, since, until time.Time) []Record {
+	records := make([]Record, 0, n)
+	step := until.Sub(since) / time.Duration(n+1)
+	for i := n; i > 0; i-- {
+		record := Record{Collection: collection, CreatedAt: since.Add(step * time.Duration(i))}
+		if collection == PostCollection {
+			record.Text = fmt.Sprintf("post number %d", i)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// rkeyAt returns a record key that sorts by t. Bluesky's record keys are timestamp identifiers, which
+// sort the same way.
+func rkeyAt(t time.Time) string {
+	return fmt.Sprintf("%016d", t.UnixMicro())
+}
+
+// Records returns the records in collection that haven't been deleted, newest record key first
+func (p *PDS) Records(collection string) []Record {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Record(nil), p.records[collection]...)
+}
+
+// Deleted returns the at:// uris of the deleted records in the order they were deleted
+func (p *PDS) Deleted() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.deleted...)
+}
+
+// ServeHTTP serves the fake PDS's XRPC endpoints
+func (p *PDS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/xrpc/com.atproto.server.createSession":
+		p.handleCreateSession(w, r)
+	case "/xrpc/com.atproto.server.refreshSession":
+		p.handleRefreshSession(w, r)
+	case "/xrpc/com.atproto.repo.listRecords":
+		p.handleListRecords(w, r)
+	case "/xrpc/com.atproto.repo.deleteRecord":
+		p.handleDeleteRecord(w, r)
+	case "/xrpc/com.atproto.repo.uploadBlob":
+		p.handleUploadBlob(w, r)
+	case "/xrpc/com.atproto.repo.putRecord":
+		p.handlePutRecord(w, r)
+	default:
+		writeError(w, http.StatusNotImplemented, "MethodNotImplemented", "Method Not Implemented")
+	}
+}
+
+// handleCreateSession checks the submitted credentials and issues an access and refresh token
+func (p *PDS) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	var creds struct {
+		Identifier string `json:"identifier"`
+		Password   string `json:"password"`
+	}
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&creds) != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequest", "Invalid request body")
+		return
+	}
+	if (creds.Identifier != p.handle && creds.Identifier != p.did) || creds.Password != p.appPassword {
+		writeError(w, http.StatusUnauthorized, "AuthenticationRequired", "Invalid identifier or password")
+		return
+	}
+	p.writeSession(w)
+}
+
+// handleRefreshSession exchanges a refresh token for a new access and refresh token
+func (p *PDS) handleRefreshSession(w http.ResponseWriter, r *http.Request) {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	p.mu.Lock()
+	// Refresh tokens can only be used once, like Bluesky's
+	ok := p.refresh[token]
+	delete(p.refresh, token)
+	p.mu.Unlock()
+	if r.Method != http.MethodPost || !ok {
+		writeError(w, http.StatusBadRequest, "ExpiredToken", "Token has expired")
+		return
+	}
+	p.writeSession(w)
+}
+
+// writeSession issues a new session for the account
+func (p *PDS) writeSession(w http.ResponseWriter) {
+	access, refresh := newToken(), newToken()
+	p.mu.Lock()
+	p.access[access] = time.Now().Add(p.accessLifetime)
+	p.refresh[refresh] = true
+	p.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"did":        p.did,
+		"handle":     p.handle,
+		"accessJwt":  access,
+		"refreshJwt": refresh,
+		"active":     true,
+	})
+}
+
+// handleListRecords writes a page of the records in a collection, newest record key first. Like
+// Bluesky's, listing records doesn't need a session, the cursor is the key of the last record of the
+// page and it is included on every page with records, so the last page is followed by an empty one.
+func (p *PDS) handleListRecords(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if r.Method != http.MethodGet || (q.Get("repo") != p.did && q.Get("repo") != p.handle) || q.Get("collection") == "" {
+		writeError(w, http.StatusBadRequest, "InvalidRequest", "Could not find repo or collection")
+		return
+	}
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 50
+	}
+	cursor := q.Get("cursor")
+
+	collection := q.Get("collection")
+	records := make([]map[string]interface{}, 0, limit)
+	var last string
+	for _, record := range p.Records(collection) {
+		if len(records) == limit {
+			break
+		}
+		if cursor != "" && record.RKey >= cursor {
+			continue
+		}
+		value := map[string]interface{}{"$type": collection, "createdAt": record.CreatedAt.UTC().Format(time.RFC3339Nano)}
+		if collection == PostCollection {
+			value["text"] = record.Text
+		} else {
+			value["subject"] = map[string]string{"uri": "at://did:plc:other/app.bsky.feed.post/" + record.RKey, "cid": "bafyfake"}
+		}
+		records = append(records, map[string]interface{}{"uri": p.uri(record), "cid": "bafyfake" + record.RKey, "value": value})
+		last = record.RKey
+	}
+
+	res := map[string]interface{}{"records": records}
+	if last != "" {
+		res["cursor"] = last
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleDeleteRecord deletes a record for a logged in client. Like Bluesky's, deleting a record that
+// doesn't exist succeeds.
+func (p *PDS) handleDeleteRecord(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Repo       string `json:"repo"`
+		Collection string `json:"collection"`
+		RKey       string `json:"rkey"`
+	}
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequest", "Invalid request body")
+		return
+	}
+	if !p.requireSession(w, r) {
+		return
+	}
+	if req.Repo != p.did && req.Repo != p.handle {
+		writeError(w, http.StatusBadRequest, "InvalidRequest", "Could not find repo")
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.throttled(w) {
+		return
+	}
+	records := p.records[req.Collection]
+	for i, record := range records {
+		if record.RKey == req.RKey {
+			p.records[req.Collection] = append(records[:i], records[i+1:]...)
+			p.deleted = append(p.deleted, p.uri(record))
+			break
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// handleUploadBlob stores a blob for a logged in client and writes a reference to it that records can
+// embed
+func (p *PDS) handleUploadBlob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusBadRequest, "InvalidRequest", "Invalid request")
+		return
+	}
+	if !p.requireSession(w, r) {
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil || len(data) == 0 {
+		writeError(w, http.StatusBadRequest, "InvalidRequest", "Invalid request body")
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	cid := "bafkrei" + hex.EncodeToString(sum[:16])
+	p.mu.Lock()
+	p.blobs[cid] = true
+	p.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"blob": map[string]interface{}{
+		"$type":    "blob",
+		"ref":      map[string]string{"$link": cid},
+		"mimeType": r.Header.Get("Content-Type"),
+		"size":     len(data),
+	}})
+}
+
+// handlePutRecord creates or replaces a post for a logged in client. Like Bluesky's, the images it
+// embeds must have been uploaded first.
+func (p *PDS) handlePutRecord(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Repo       string `json:"repo"`
+		Collection string `json:"collection"`
+		RKey       string `json:"rkey"`
+		Record     struct {
+			Text      string    `json:"text"`
+			CreatedAt time.Time `json:"createdAt"`
+			Embed     struct {
+				Images []struct {
+					Image struct {
+						Ref struct {
+							Link string `json:"$link"`
+						} `json:"ref"`
+					} `json:"image"`
+				} `json:"images"`
+			} `json:"embed"`
+		} `json:"record"`
+	}
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil || req.RKey == "" {
+		writeError(w, http.StatusBadRequest, "InvalidRequest", "Invalid request body")
+		return
+	}
+	if !p.requireSession(w, r) {
+		return
+	}
+	if req.Repo != p.did && req.Repo != p.handle {
+		writeError(w, http.StatusBadRequest, "InvalidRequest", "Could not find repo")
+		return
+	}
+	if req.Collection != PostCollection {
+		writeError(w, http.StatusBadRequest, "InvalidRequest", "The fake PDS only accepts posts")
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, image := range req.Record.Embed.Images {
+		if !p.blobs[image.Image.Ref.Link] {
+			writeError(w, http.StatusBadRequest, "BlobNotFound", "Could not find blob: "+image.Image.Ref.Link)
+			return
+		}
+	}
+	if p.throttled(w) {
+		return
+	}
+
+	record := Record{
+		Collection: req.Collection,
+		RKey:       req.RKey,
+		CreatedAt:  req.Record.CreatedAt,
+		Text:       req.Record.Text,
+		Images:     len(req.Record.Embed.Images),
+	}
+	records := p.records[req.Collection]
+	i := sort.Search(len(records), func(i int) bool { return records[i].RKey <= record.RKey })
+	if i < len(records) && records[i].RKey == record.RKey {
+		records[i] = record
+	} else {
+		records = append(records[:i], append([]Record{record}, records[i:]...)...)
+	}
+	p.records[req.Collection] = records
+	writeJSON(w, http.StatusOK, map[string]string{"uri": p.uri(record), "cid": "bafyfake" + record.RKey})
+}
+
+// throttled writes a 429 response to every RateLimit+1th write, reporting whether it did. p.mu must
+// be held.
+func (p *PDS) throttled(w http.ResponseWriter) bool {
+	p.writes++
+	if p.rateLimit == 0 || p.writes%(p.rateLimit+1) != 0 {
+		return false
+	}
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(p.rateLimit))
+	w.Header().Set("RateLimit-Remaining", "0")
+	w.Header().Set("RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Second).Unix(), 10))
+	writeError(w, http.StatusTooManyRequests, "RateLimitExceeded", "Rate Limit Exceeded")
+	return true
+}
+
+// requireSession writes an error response if r doesn't carry an access token that hasn't expired,
+// reporting whether it does
+func (p *PDS) requireSession(w http.ResponseWriter, r *http.Request) bool {
+	name, message, ok := p.authorized(r)
+	if !ok {
+		status := http.StatusUnauthorized
+		if name == "ExpiredToken" {
+			status = http.StatusBadRequest
+		}
+		writeError(w, status, name, message)
+	}
+	return ok
+}
+
+// authorized reports whether r carries an access token that hasn't expired, along with the error to
+// respond with if it doesn't
+func (p *PDS) authorized(r *http.Request) (name, message string, ok bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return "AuthenticationRequired", "Authentication Required", false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	expiry, ok := p.access[token]
+	switch {
+	case !ok:
+		return "InvalidToken", "Token could not be verified", false
+	case time.Now().After(expiry):
+		return "ExpiredToken", "Token has expired", false
+	}
+	return "", "", true
+}
+
+// uri returns the at:// uri of record
+func (p *PDS) uri(record Record) string {
+	return "at://" + p.did + "/" + record.Collection + "/" + record.RKey
+}
+
+// newToken returns a random token
+func newToken() string {
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+	return hex.EncodeToString(token)
+}
+
+// writeJSON writes v as a JSON response with status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an XRPC error response
+func writeError(w http.ResponseWriter, status int, name, message string) {
+	writeJSON(w, status, map[string]string{"error": name, "message": message})
+}
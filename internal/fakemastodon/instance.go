@@ -0,0 +1,401 @@
+// Package fakemastodon is an offline stand-in for the endpoints of Mastodon's REST API used to delete
+// an account's statuses, boosts and favourites, and to copy tweets to it. It checks the access token,
+// pages statuses by max_id the way Mastodon does and keeps track of which statuses have been deleted,
+// so that MastodonDeleter can be run end to end without a network.
+package fakemastodon
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// accountID is the id of the server's only account
+const accountID = "109000000000000001"
+
+// Status is a status posted or boosted by the server's account, or one it has favourited
+type Status struct {
+	ID        string    `json:"id"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	// ReblogOf is the id of the status this boosts. The boosted status itself isn't served.
+	ReblogOf string `json:"reblog_of,omitempty"`
+	// Media is the number of media attachments
+	Media int `json:"media,omitempty"`
+}
+
+// Options configure a fake server
+type Options struct {
+	// Username and AccessToken are the username of the server's only account and the token that
+	// authorizes calls on its behalf
+	Username    string
+	AccessToken string
+	// Statuses are the statuses and boosts posted by the account
+	Statuses []Status
+	// Favourites are the statuses the account has favourited, most recently favourited first
+	Favourites []Status
+	// RateLimit throttles every RateLimit+1th call that changes a status with a 429 response whose
+	// X-RateLimit-Reset header is a second later, to check that throttled calls are retried. Calls
+	// aren't throttled if RateLimit is zero.
+	RateLimit int
+}
+
+// Instance is a fake Mastodon server. It implements http.Handler and is safe for concurrent use.
+type Instance struct {
+	username    string
+	accessToken string
+	rateLimit   int
+
+	mu         sync.Mutex
+	statuses   []Status    // newest first
+	favourites []favourite // most recently favourited first
+	deleted    []string
+	writes     int
+	media      map[string]bool   // ids of the uploaded media attachments
+	posted     map[string]string // id of the status posted with each idempotency key
+	lastID     int64
+}
+
+// NewInstance creates a fake Mastodon server from opts
+func NewInstance(opts Options) *Instance {
+	statuses := append([]Status(nil), opts.Statuses...)
+	sort.SliceStable(statuses, func(i, j int) bool { return idLess(statuses[j].ID, statuses[i].ID) })
+	// Favourites are numbered from the oldest, so the most recent has the highest id
+	favourites := make([]favourite, len(opts.Favourites))
+	for i, status := range opts.Favourites {
+		favourites[i] = favourite{id: len(opts.Favourites) - i, status: status}
+	}
+	return &Instance{
+		username:    opts.Username,
+		accessToken: opts.AccessToken,
+		rateLimit:   opts.RateLimit,
+		statuses:    statuses,
+		favourites:  favourites,
+		media:       make(map[string]bool),
+		posted:      make(map[string]string),
+	}
+}
+
+// favourite is a status the account has favourited, along with the id of the favourite
+type favourite struct {
+	id     int
+	status Status
+}
+
+// NewServer starts serving instance on a local port. The caller should call Close on the returned server
+// when finished and pass its URL to MastodonDeleter as the server.
+func NewServer(instance *Instance) *httptest.Server {
+	return httptest.NewServer(instance)
+}
+
+// GenerateStatuses returns n statuses posted evenly between since and until, newest first. Like
+// Mastodon's, their ids grow with their creation time.
+func GenerateStatuses(n int, since, until time.Time) []Status {
+	statuses := make([]Status, 0, n)
+	step := until.Sub(since) / time.Duration(n+1)
+	for i := n; i > 0; i-- {
+		createdAt := since.Add(step * time.Duration(i))
+		statuses = append(statuses, Status{
+			ID:        strconv.FormatInt(createdAt.UnixMilli()<<16, 10),
+			Content:   fmt.Sprintf("<p>status number %d</p>", i),
+			CreatedAt: createdAt,
+		})
+	}
+	return statuses
+}
+
+// idLess reports whether status id a is older than b. Ids are numbers too large to compare as
+// strings of different lengths.
+func idLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+// Statuses returns the statuses and boosts that haven't been deleted, newest first
+func (m *Instance) Statuses() []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Status(nil), m.statuses...)
+}
+
+// Favourites returns the statuses the account still favourites, most recently favourited first
+func (m *Instance) Favourites() []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	statuses := make([]Status, len(m.favourites))
+	for i, f := range m.favourites {
+		statuses[i] = f.status
+	}
+	return statuses
+}
+
+// Deleted returns the ids of the deleted statuses and boosts in the order they were deleted
+func (m *Instance) Deleted() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.deleted...)
+}
+
+// ServeHTTP serves the fake API
+func (m *Instance) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+m.accessToken {
+		writeError(w, http.StatusUnauthorized, "The access token is invalid")
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/"), "/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/accounts/verify_credentials":
+		writeJSON(w, http.StatusOK, map[string]string{"id": accountID, "username": m.username, "acct": m.username})
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[0] == "accounts" && parts[2] == "statuses":
+		m.handleStatuses(w, r, parts[1])
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/favourites":
+		m.handleFavourites(w, r)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v2/media":
+		m.handleUpload(w, r)
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "media":
+		m.handleMedia(w, parts[1])
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/statuses":
+		m.handlePost(w, r)
+	case r.Method == http.MethodDelete && len(parts) == 2 && parts[0] == "statuses":
+		m.handleWrite(w, func() bool { return m.remove(func(st Status) bool { return st.ID == parts[1] && st.ReblogOf == "" }) })
+	case r.Method == http.MethodPost && len(parts) == 3 && parts[0] == "statuses" && parts[2] == "unreblog":
+		m.handleWrite(w, func() bool { m.remove(func(st Status) bool { return st.ReblogOf == parts[1] }); return true })
+	case r.Method == http.MethodPost && len(parts) == 3 && parts[0] == "statuses" && parts[2] == "unfavourite":
+		m.handleWrite(w, func() bool { m.unfavourite(parts[1]); return true })
+	default:
+		writeError(w, http.StatusNotFound, "Record not found")
+	}
+}
+
+// handleStatuses writes a page of the account's statuses and boosts older than max_id, newest first
+func (m *Instance) handleStatuses(w http.ResponseWriter, r *http.Request, id string) {
+	if id != accountID {
+		writeError(w, http.StatusNotFound, "Record not found")
+		return
+	}
+	limit := pageLimit(r)
+	maxID := r.URL.Query().Get("max_id")
+
+	page := []map[string]interface{}{}
+	for _, status := range m.Statuses() {
+		if len(page) == limit {
+			break
+		}
+		if maxID == "" || idLess(status.ID, maxID) {
+			page = append(page, m.render(status))
+		}
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// handleFavourites writes a page of the account's favourites older than max_id. Like Mastodon's,
+// favourites are paged by the id of the favourite rather than of the status, which is only given in
+// the Link header.
+func (m *Instance) handleFavourites(w http.ResponseWriter, r *http.Request) {
+	limit := pageLimit(r)
+	maxID := -1
+	if v := r.URL.Query().Get("max_id"); v != "" {
+		var err error
+		if maxID, err = strconv.Atoi(v); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "invalid max_id")
+			return
+		}
+	}
+
+	m.mu.Lock()
+	var favourites []favourite
+	for _, f := range m.favourites {
+		if len(favourites) < limit && (maxID < 0 || f.id < maxID) {
+			favourites = append(favourites, f)
+		}
+	}
+	m.mu.Unlock()
+
+	page := make([]map[string]interface{}, 0, len(favourites))
+	for _, f := range favourites {
+		page = append(page, m.render(f.status))
+	}
+	if len(favourites) > 0 {
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s/api/v1/favourites?max_id=%d>; rel="next"`, r.Host, favourites[len(favourites)-1].id))
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// handleUpload stores an uploaded media attachment. Like Mastodon's, the attachment is processed
+// straight away when it is small.
+func (m *Instance) handleUpload(w http.ResponseWriter, r *http.Request) {
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "Validation failed: File can't be blank")
+		return
+	}
+	file.Close()
+
+	m.mu.Lock()
+	id := m.nextID()
+	m.media[id] = true
+	m.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]string{"id": id, "url": "http://" + r.Host + "/media/" + id})
+}
+
+// handleMedia writes an uploaded media attachment
+func (m *Instance) handleMedia(w http.ResponseWriter, id string) {
+	m.mu.Lock()
+	ok := m.media[id]
+	m.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "Record not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id": id, "url": "/media/" + id})
+}
+
+// handlePost posts a status. Like Mastodon's, posting again with the same Idempotency-Key header
+// returns the status that was already posted.
+func (m *Instance) handlePost(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Status   string   `json:"status"`
+		MediaIDs []string `json:"media_ids"`
+	}
+	if json.NewDecoder(r.Body).Decode(&req) != nil || (req.Status == "" && len(req.MediaIDs) == 0) {
+		writeError(w, http.StatusUnprocessableEntity, "Validation failed: Text can't be blank")
+		return
+	}
+	if m.throttled(w) {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := r.Header.Get("Idempotency-Key")
+	if id, ok := m.posted[key]; ok && key != "" {
+		for _, status := range m.statuses {
+			if status.ID == id {
+				writeJSON(w, http.StatusOK, m.render(status))
+				return
+			}
+		}
+	}
+	for _, id := range req.MediaIDs {
+		if !m.media[id] {
+			writeError(w, http.StatusUnprocessableEntity, "Validation failed: media not found")
+			return
+		}
+	}
+	status := Status{ID: m.nextID(), Content: "<p>" + html.EscapeString(req.Status) + "</p>", CreatedAt: time.Now(), Media: len(req.MediaIDs)}
+	m.statuses = append([]Status{status}, m.statuses...)
+	if key != "" {
+		m.posted[key] = status.ID
+	}
+	writeJSON(w, http.StatusOK, m.render(status))
+}
+
+// nextID returns the id of a new status or attachment, which is newer than every existing one. m.mu
+// must be held.
+func (m *Instance) nextID() string {
+	m.lastID = max(m.lastID+1, time.Now().UnixMilli()<<16)
+	return strconv.FormatInt(m.lastID, 10)
+}
+
+// throttled writes a 429 response to every RateLimit+1th call that changes a status, reporting whether
+// it did
+func (m *Instance) throttled(w http.ResponseWriter) bool {
+	m.mu.Lock()
+	m.writes++
+	throttled := m.rateLimit > 0 && m.writes%(m.rateLimit+1) == 0
+	m.mu.Unlock()
+
+	if throttled {
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(m.rateLimit))
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", time.Now().Add(time.Second).UTC().Format(time.RFC3339Nano))
+		writeError(w, http.StatusTooManyRequests, "Too many requests")
+	}
+	return throttled
+}
+
+// handleWrite runs a call that changes a status, throttling it if the server is rate limited.
+// change reports whether the status existed.
+func (m *Instance) handleWrite(w http.ResponseWriter, change func() bool) {
+	if m.throttled(w) {
+		return
+	}
+	if !change() {
+		writeError(w, http.StatusNotFound, "Record not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// remove deletes the first status matching match, reporting whether there was one
+func (m *Instance) remove(match func(Status) bool) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, status := range m.statuses {
+		if match(status) {
+			m.statuses = append(m.statuses[:i], m.statuses[i+1:]...)
+			m.deleted = append(m.deleted, status.ID)
+			return true
+		}
+	}
+	return false
+}
+
+// unfavourite removes the account's favourite of the status with id. Like Mastodon's, removing a
+// favourite that doesn't exist succeeds.
+func (m *Instance) unfavourite(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, f := range m.favourites {
+		if f.status.ID == id {
+			m.favourites = append(m.favourites[:i], m.favourites[i+1:]...)
+			return
+		}
+	}
+}
+
+// render returns status the way the API serves it
+func (m *Instance) render(status Status) map[string]interface{} {
+	st := map[string]interface{}{
+		"id":         status.ID,
+		"created_at": status.CreatedAt.UTC().Format(time.RFC3339Nano),
+		"content":    status.Content,
+		"reblog":     nil,
+	}
+	if status.ReblogOf != "" {
+		st["content"] = ""
+		st["reblog"] = map[string]interface{}{"id": status.ReblogOf, "created_at": status.CreatedAt.UTC().Format(time.RFC3339Nano)}
+	}
+	return st
+}
+
+// pageLimit returns the page size asked for by r, which Mastodon caps at 40
+func pageLimit(r *http.Request) int {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 || limit > 40 {
+		return 20
+	}
+	return limit
+}
+
+// writeJSON writes v as a JSON response with status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error response the way the API does
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
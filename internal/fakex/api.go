@@ -0,0 +1,268 @@
+package fakex
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// accountID is the id of the site's only account in the API
+const accountID = "1000"
+
+// defaultTokenLifetime is how long access tokens issued by the fake API are valid, like X's
+const defaultTokenLifetime = 2 * time.Hour
+
+// APIOptions configure a fake API
+type APIOptions struct {
+	// TokenLifetime is how long access tokens are valid for. Set it to something short to check
+	// that tokens are refreshed. Two hours, like X's, is used if TokenLifetime is zero.
+	TokenLifetime time.Duration
+}
+
+// API is a fake of the endpoints of the X API v2 used to delete tweets, serving the tweets and
+// likes of a fake site. It also serves the OAuth 2.0 authorization endpoint, which approves every
+// request without asking and redirects straight back to the client, and the token endpoint, which
+// checks the PKCE code verifier and issues refresh tokens. It implements http.Handler and is safe for
+// concurrent use.
+type API struct {
+	site          *Site
+	tokenLifetime time.Duration
+
+	mu       sync.Mutex
+	codes    map[string]apiGrant
+	access   map[string]time.Time // expiry of each access token
+	refresh  map[string]bool
+	nextCode int
+}
+
+// apiGrant is an authorization code waiting to be exchanged for a token
+type apiGrant struct {
+	challenge   string
+	redirectURI string
+}
+
+// NewAPI creates a fake API for site
+func NewAPI(site *Site, opts APIOptions) *API {
+	lifetime := opts.TokenLifetime
+	if lifetime == 0 {
+		lifetime = defaultTokenLifetime
+	}
+	return &API{
+		site:          site,
+		tokenLifetime: lifetime,
+		codes:         make(map[string]apiGrant),
+		access:        make(map[string]time.Time),
+		refresh:       make(map[string]bool),
+	}
+}
+
+// ServeHTTP serves the fake API
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/i/oauth2/authorize":
+		a.handleAuthorize(w, r)
+		return
+	case "/2/oauth2/token":
+		a.handleToken(w, r)
+		return
+	}
+	if !a.authorized(r) {
+		writeAPIError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/2/"), "/")
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "users" && parts[1] == "me":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]string{"id": accountID, "username": a.site.username}})
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[0] == "users" && parts[2] == "tweets":
+		a.handleTimeline(w, r, parts[1], a.userTweets)
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[0] == "users" && parts[2] == "liked_tweets":
+		a.handleTimeline(w, r, parts[1], a.likedTweets)
+	case r.Method == http.MethodDelete && len(parts) == 2 && parts[0] == "tweets":
+		writeDeleted(w, "deleted", a.site.delete(parts[1]))
+	case r.Method == http.MethodDelete && len(parts) == 4 && parts[0] == "users" && parts[2] == "likes" && parts[1] == accountID:
+		writeDeleted(w, "liked", !a.site.unlike(parts[3]))
+	case r.Method == http.MethodDelete && len(parts) == 4 && parts[0] == "users" && parts[2] == "retweets" && parts[1] == accountID:
+		writeDeleted(w, "retweeted", !a.site.unrepost(parts[3]))
+	default:
+		writeAPIError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+// handleAuthorize approves an authorization request and redirects back to the client with a code
+func (a *API) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirect.Scheme == "" || q.Get("response_type") != "code" || q.Get("client_id") == "" ||
+		q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	a.mu.Lock()
+	a.nextCode++
+	code := "code-" + strconv.Itoa(a.nextCode)
+	a.codes[code] = apiGrant{challenge: q.Get("code_challenge"), redirectURI: q.Get("redirect_uri")}
+	a.mu.Unlock()
+
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", q.Get("state"))
+	redirect.RawQuery = values.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// handleToken exchanges an authorization code or refresh token for a new access token and refresh token
+func (a *API) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil || r.PostForm.Get("client_id") == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		grant, ok := a.codes[r.PostForm.Get("code")]
+		delete(a.codes, r.PostForm.Get("code"))
+		challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !ok || grant.redirectURI != r.PostForm.Get("redirect_uri") ||
+			grant.challenge != base64.RawURLEncoding.EncodeToString(challenge[:]) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+	case "refresh_token":
+		// Refresh tokens can only be used once, like X's
+		if !a.refresh[r.PostForm.Get("refresh_token")] {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		delete(a.refresh, r.PostForm.Get("refresh_token"))
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	access, refresh := newToken(), newToken()
+	a.access[access] = time.Now().Add(a.tokenLifetime)
+	a.refresh[refresh] = true
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"token_type":    "bearer",
+		"access_token":  access,
+		"refresh_token": refresh,
+		"expires_in":    int(a.tokenLifetime.Seconds()),
+		"scope":         "tweet.read tweet.write users.read like.read like.write offline.access",
+	})
+}
+
+// authorized reports whether r carries an access token that hasn't expired
+func (a *API) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	expiry, ok := a.access[token]
+	return ok && time.Now().Before(expiry)
+}
+
+// handleTimeline writes a page of the tweets returned by list for the user with id. Pages hold
+// max_results tweets, and the pagination token is the id of the first tweet of the next page, so
+// deleting the tweets of a page doesn't move the tweets of the next one.
+func (a *API) handleTimeline(w http.ResponseWriter, r *http.Request, id string, list func(q url.Values) ([]Tweet, error)) {
+	if id != accountID {
+		writeAPIError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	q := r.URL.Query()
+	tweets, err := list(q)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	max, err := strconv.Atoi(q.Get("max_results"))
+	if err != nil || max < 1 || max > 100 {
+		max = 10
+	}
+	start := 0
+	if token := q.Get("pagination_token"); token != "" {
+		for start < len(tweets) && tweets[start].ID != token {
+			start++
+		}
+		if start == len(tweets) {
+			writeAPIError(w, http.StatusBadRequest, "invalid pagination_token")
+			return
+		}
+	}
+
+	end := min(start+max, len(tweets))
+	data := make([]map[string]interface{}, 0, end-start)
+	for _, tweet := range tweets[start:end] {
+		t := map[string]interface{}{"id": tweet.ID, "text": tweet.Text, "created_at": tweet.CreatedAt.UTC().Format(time.RFC3339)}
+		if tweet.RepostOf != "" {
+			t["referenced_tweets"] = []map[string]string{{"type": "retweeted", "id": tweet.RepostOf}}
+		}
+		data = append(data, t)
+	}
+	meta := map[string]interface{}{"result_count": len(data)}
+	if end < len(tweets) {
+		meta["next_token"] = tweets[end].ID
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": data, "meta": meta})
+}
+
+// userTweets returns the account's tweets and reposts between the start_time and end_time in q,
+// newest first. start_time is inclusive and end_time is exclusive.
+func (a *API) userTweets(q url.Values) ([]Tweet, error) {
+	since, until := time.Time{}, time.Now()
+	var err error
+	if v := q.Get("start_time"); v != "" {
+		if since, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, err
+		}
+	}
+	if v := q.Get("end_time"); v != "" {
+		if until, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, err
+		}
+	}
+
+	var tweets []Tweet
+	for _, tweet := range a.site.Tweets() {
+		if !tweet.CreatedAt.Before(since) && tweet.CreatedAt.Before(until) {
+			tweets = append(tweets, tweet)
+		}
+	}
+	return tweets, nil
+}
+
+// likedTweets returns the tweets the account likes, most recently liked first
+func (a *API) likedTweets(q url.Values) ([]Tweet, error) {
+	return a.site.Likes(), nil
+}
+
+// newToken returns a random token
+func newToken() string {
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+	return hex.EncodeToString(token)
+}
+
+// writeDeleted writes the response of a DELETE endpoint, which reports the new state of field
+func writeDeleted(w http.ResponseWriter, field string, value bool) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]bool{field: value}})
+}
+
+// writeAPIError writes an error response the way the API does
+func writeAPIError(w http.ResponseWriter, status int, detail string) {
+	writeJSON(w, status, map[string]interface{}{"title": http.StatusText(status), "detail": detail, "status": status})
+}
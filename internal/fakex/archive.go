@@ -0,0 +1,52 @@
+package fakex
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WriteArchive writes the tweets to dir in the layout of the data archive X lets accounts download,
+// with their media in data/tweets_media. media maps the id of a tweet to the names and contents of the
+// files attached to it, and may be nil. Reposts are written the way X archives them, as text starting
+// with "RT @" and the reposted account.
+func WriteArchive(dir string, tweets []Tweet, media map[string]map[string][]byte) error {
+	entries := make([]map[string]interface{}, 0, len(tweets))
+	for _, tweet := range tweets {
+		text := tweet.Text
+		if tweet.RepostOf != "" {
+			text = "RT @someone: " + text
+		}
+		entry := map[string]interface{}{
+			"id_str":     tweet.ID,
+			"full_text":  text,
+			"created_at": tweet.CreatedAt.UTC().Format(time.RubyDate),
+			"entities":   map[string]interface{}{"urls": []interface{}{}},
+		}
+		if tweet.InReplyTo != "" {
+			entry["in_reply_to_status_id_str"] = tweet.InReplyTo
+		}
+		entries = append(entries, map[string]interface{}{"tweet": entry})
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	mediaDir := filepath.Join(dir, "data", "tweets_media")
+	if err := os.MkdirAll(mediaDir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data", "tweets.js"), append([]byte("window.YTD.tweets.part0 = "), data...), 0o644); err != nil {
+		return err
+	}
+	for id, files := range media {
+		for name, contents := range files {
+			if err := os.WriteFile(filepath.Join(mediaDir, id+"-"+name), contents, 0o644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
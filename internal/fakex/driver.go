@@ -0,0 +1,529 @@
+package fakex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/chromedp/kb"
+
+	"tweetdeleter/internal"
+)
+
+// Driver drives a fake site without a browser. Its pages model the site's UI as a small state
+// machine keyed by the element names TweetDeleter uses, like "tweet.moreButton", and implement
+// TweetDeleter's scripts natively, so the deletion logic can be run in memory and in milliseconds.
+// Locators are ignored, so this can't catch broken selectors, but waiting on an element that the
+// page isn't showing fails immediately with internal.ErrWaitTimeout.
+type Driver struct {
+	site *Site
+}
+
+// NewDriver creates a driver for site
+func NewDriver(site *Site) *Driver {
+	return &Driver{site: site}
+}
+
+// NewPage opens a new in-memory page. Like a fresh chrome profile, it starts logged out.
+func (d *Driver) NewPage() (internal.Page, error) {
+	p := &page{site: d.site}
+	p.load(&url.URL{Scheme: "https", Host: "x.com", Path: "/"})
+	return p, nil
+}
+
+// page is an in-memory page showing a fake site
+type page struct {
+	site *Site
+
+	mu         sync.Mutex
+	url        *url.URL
+	loggedIn   bool
+	epoch      int    // the site's epoch when the page logged in
+	session    string // token of the site session the page logged in with, for calls made over HTTP
+	csrf       string // CSRF token of session
+	loginStep  string // "username", "password", "challenge" or "failed"
+	inputs     map[string]string
+	found      map[string]bool
+	results    []Tweet
+	live       bool
+	marked     string // id of the tweet marked by ScriptMarkFirstTweet or ScriptMarkTweet
+	menuOpen   bool
+	sheetOpen  bool
+	attached   []string // names of the files chosen in the composer's media input
+	refused    string   // error shown by the composer when posting was refused
+	closed     bool
+	listenerMu sync.Mutex
+	listeners  []func(ev interface{})
+}
+
+// visible reports whether the element called name is currently shown
+func (p *page) visible(name string) bool {
+	path := p.url.Path
+	switch name {
+	case "login.usernameInput", "login.nextButton":
+		return path == "/i/flow/login" && p.loginStep == "username"
+	case "login.passwordInput", "login.submitButton":
+		return path == "/i/flow/login" && (p.loginStep == "password" || p.loginStep == "failed")
+	case "login.challengeInput":
+		return path == "/i/flow/login" && p.loginStep == "challenge"
+	case "login.errorMessage":
+		return path == "/i/flow/login" && p.loginStep == "failed"
+	case "session.loggedIn":
+		return p.signedIn() && path != "/i/flow/login"
+	case "session.loggedOut":
+		// X swaps in its logged out UI when the session is ended under an open page
+		return p.loggedIn && !p.signedIn()
+	case "search.activeLatestTab":
+		return p.signedIn() && path == "/search" && p.live
+	case "search.emptyState":
+		return p.signedIn() && path == "/search" && len(p.results) == 0
+	case "search.errorBanner":
+		// The site never fails to load results
+		return false
+	case "tweet.article", "tweet.permalink", "tweet.text", "tweet.moreButton":
+		return p.signedIn() && (path == "/search" || p.onProfile()) && len(p.results) > 0
+	case "tweet.pinnedLabel":
+		return p.signedIn() && p.onProfile() && p.marked != "" && p.marked == p.site.pinned
+	case "tweet.menu", "tweet.menuItem":
+		return p.menuOpen
+	case "tweet.deleteMenuItem":
+		// Only "en" menu text is rendered
+		return p.menuOpen && p.site.lang == "en"
+	case "tweet.confirmButton":
+		return p.sheetOpen
+	case "compose.textArea", "compose.mediaInput", "compose.postButton":
+		return p.signedIn() && path == "/compose/post"
+	case "compose.error":
+		return p.signedIn() && path == "/compose/post" && p.refused != ""
+	}
+	return false
+}
+
+// onProfile reports whether the page shows the account's profile
+func (p *page) onProfile() bool {
+	return p.url.Path == "/"+p.site.username
+}
+
+// signedIn reports whether the page has logged in and the site hasn't ended its session since
+func (p *page) signedIn() bool {
+	return p.loggedIn && p.site.signedIn(p.epoch)
+}
+
+// load shows the page at u, redirecting to the login flow if it needs a session
+func (p *page) load(u *url.URL) {
+	p.url = u
+	p.found = make(map[string]bool)
+	p.inputs = make(map[string]string)
+	p.menuOpen, p.sheetOpen, p.marked = false, false, ""
+	p.attached, p.refused = nil, ""
+
+	switch u.Path {
+	case "/i/flow/login":
+		p.loginStep = "username"
+		return
+	case "/", "/home", "/explore", "/search", "/compose/post", "/" + p.site.username:
+	default:
+		return
+	}
+	if !p.signedIn() {
+		p.url = &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/i/flow/login", RawQuery: "redirect_after_login=" + u.Path}
+		p.loginStep = "username"
+		return
+	}
+	switch u.Path {
+	case "/search":
+		p.live = u.Query().Get("f") == "live"
+		p.results = p.site.search(u.Query().Get("q"))
+	case "/" + p.site.username:
+		p.results = p.site.profile()
+	}
+}
+
+// Navigate loads rawURL
+func (p *page) Navigate(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return context.Canceled
+	}
+	p.load(u)
+	return nil
+}
+
+// Location returns the current URL
+func (p *page) Location(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.url.String(), nil
+}
+
+// Find fails immediately if the element called name isn't shown, since nothing changes an
+// in-memory page while it is being waited on
+func (p *page) Find(ctx context.Context, name string, locators internal.Locators, scope string, timeout time.Duration) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.visible(name) {
+		return 0, fmt.Errorf("could not find %s: %w", name, internal.ErrWaitTimeout)
+	}
+	p.found[name] = true
+	return 0, nil
+}
+
+// Click clicks the element called name
+func (p *page) Click(ctx context.Context, name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.located(name); err != nil {
+		return err
+	}
+
+	switch name {
+	case "login.nextButton":
+		p.inputs["username"] = p.inputs["login.usernameInput"]
+		p.loginStep = "password"
+	case "login.submitButton":
+		switch p.site.checkLogin(p.inputs["username"], p.inputs["login.passwordInput"]) {
+		case loginFailed:
+			p.loginStep = "failed"
+		case loginChallenge:
+			p.loginStep = "challenge"
+		default:
+			token, csrf, err := p.site.startSession()
+			if err != nil {
+				return err
+			}
+			p.loggedIn, p.epoch, p.session, p.csrf = true, p.site.currentEpoch(), token, csrf
+			next := p.url.Query().Get("redirect_after_login")
+			if !strings.HasPrefix(next, "/") {
+				next = "/home"
+			}
+			p.load(p.url.ResolveReference(&url.URL{Path: next}))
+		}
+	case "tweet.moreButton":
+		p.menuOpen = true
+	case "tweet.deleteMenuItem":
+		p.menuOpen, p.sheetOpen = false, true
+	case "tweet.confirmButton":
+		p.sheetOpen = false
+		if p.marked != "" && p.deleteResult(p.marked) {
+			p.marked = ""
+		}
+	case "compose.postButton":
+		if !p.composeReady() {
+			return nil
+		}
+		_, refused := p.site.post(p.inputs["compose.textArea"], p.attached)
+		if refused != "" {
+			p.refused = refused
+			return nil
+		}
+		p.emit(&internal.ResponseEvent{URL: p.url.ResolveReference(&url.URL{Path: "/i/api/graphql/SoVnbfCycZ7fERGCwpZkYA/CreateTweet"}).String(), Status: 200})
+		// X swaps in its logged out UI instead of closing the composer when posting ended the session
+		if p.signedIn() {
+			p.load(p.url.ResolveReference(&url.URL{Path: "/home"}))
+		}
+	}
+	return nil
+}
+
+// composeReady reports whether the composer's post button is enabled, which like X's it isn't while
+// the post is empty or too long
+func (p *page) composeReady() bool {
+	n := len([]rune(p.inputs["compose.textArea"]))
+	return p.visible("compose.postButton") && (n > 0 || len(p.attached) > 0) && n <= maxTweetChars
+}
+
+// deleteResult deletes the tweet with id from the site and the search results the way the site's
+// delete API would, reporting whether it existed
+func (p *page) deleteResult(id string) bool {
+	if !p.site.delete(id) {
+		return false
+	}
+	p.emit(&internal.ResponseEvent{URL: p.url.ResolveReference(&url.URL{Path: "/i/api/graphql/VaenaVgh5q5ih7kvyVjgtg/DeleteTweet"}).String(), Status: 200})
+	for i, tweet := range p.results {
+		if tweet.ID == id {
+			p.results = append(p.results[:i:i], p.results[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// Type types text into the element called name
+func (p *page) Type(ctx context.Context, name, text string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.located(name); err != nil {
+		return err
+	}
+
+	p.inputs[name] += text
+	return nil
+}
+
+// UploadFiles chooses the files at paths in the file input called name. Only their names are kept,
+// and uploads finish immediately.
+func (p *page) UploadFiles(ctx context.Context, name string, paths []string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.located(name); err != nil {
+		return err
+	}
+	for _, path := range paths {
+		p.attached = append(p.attached, filepath.Base(path))
+	}
+	return nil
+}
+
+// PressKey sends key to the page. Escape closes the tweet menu and confirmation sheet.
+func (p *page) PressKey(ctx context.Context, key string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key == kb.Escape {
+		p.menuOpen, p.sheetOpen = false, false
+	}
+	return nil
+}
+
+// WaitVisible waits until the element called name is visible
+func (p *page) WaitVisible(ctx context.Context, name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.located(name); err != nil {
+		return err
+	}
+	if !p.visible(name) {
+		return fmt.Errorf("%s is not visible: %w", name, internal.ErrWaitTimeout)
+	}
+	return nil
+}
+
+// located returns an error if the element called name hasn't been found since the page was loaded
+func (p *page) located(name string) error {
+	if !p.found[name] || !p.visible(name) {
+		return fmt.Errorf("%s has not been found on the page", name)
+	}
+	return nil
+}
+
+// Evaluate runs the script natively
+func (p *page) Evaluate(ctx context.Context, script internal.Script, res interface{}, args ...interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	v, err := p.script(script, args)
+	if err != nil {
+		return err
+	}
+	return store(v, res)
+}
+
+// Poll runs the script natively, failing immediately if it isn't satisfied since nothing changes
+// an in-memory page while it is being waited on
+func (p *page) Poll(ctx context.Context, script internal.Script, res interface{}, opts internal.PollOptions, args ...interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	v, err := p.script(script, args)
+	if err != nil {
+		return err
+	}
+	if v == nil || v == false {
+		return internal.ErrWaitTimeout
+	}
+	return store(v, res)
+}
+
+// script returns the result of the script with the given name called with args, or nil if it
+// isn't satisfied
+func (p *page) script(script internal.Script, args []interface{}) (interface{}, error) {
+	switch script.Name {
+	case internal.ScriptSearchResults:
+		switch {
+		case !p.visible("search.activeLatestTab"):
+			return nil, nil
+		case len(p.results) == 0:
+			return map[string]interface{}{"state": "empty", "index": 0}, nil
+		default:
+			return map[string]interface{}{"state": "tweets", "index": 0}, nil
+		}
+	case internal.ScriptCount:
+		name, _ := args[0].(string)
+		switch {
+		case !p.visible(name):
+			return 0, nil
+		case name == "tweet.article":
+			return len(p.results), nil
+		}
+		return 1, nil
+	case internal.ScriptMarkFirstTweet:
+		if !p.visible("tweet.article") {
+			return nil, nil
+		}
+		p.marked = p.results[0].ID
+		return map[string]interface{}{"id": p.marked, "index": 0}, nil
+	case internal.ScriptMarkTweet:
+		id, _ := args[1].(string)
+		for _, tweet := range p.results {
+			if tweet.ID == id {
+				p.marked = id
+				return true, nil
+			}
+		}
+		return false, nil
+	case internal.ScriptDropdownReady:
+		if !p.menuOpen {
+			return nil, nil
+		}
+		return map[string]interface{}{"index": 0}, nil
+	case internal.ScriptTweetDeleted:
+		return !p.sheetOpen && p.marked == "", nil
+	case internal.ScriptTweetText:
+		for _, tweet := range p.results {
+			if tweet.ID == p.marked && p.visible("tweet.text") {
+				return map[string]interface{}{"text": tweet.Text, "index": 0}, nil
+			}
+		}
+		return map[string]interface{}{"text": "", "index": -1}, nil
+	case internal.ScriptRemoveMarked:
+		for i, tweet := range p.results {
+			if tweet.ID == p.marked {
+				p.results = append(p.results[:i:i], p.results[i+1:]...)
+				break
+			}
+		}
+		p.marked = ""
+		return true, nil
+	case internal.ScriptCollectTweets:
+		if !p.visible("tweet.article") {
+			return nil, nil
+		}
+		max, _ := args[3].(int)
+		ids := make([]string, 0, max)
+		for _, tweet := range p.results[:min(max, len(p.results))] {
+			ids = append(ids, tweet.ID)
+		}
+		return map[string]interface{}{"ids": ids, "index": 0}, nil
+	case internal.ScriptBatchDelete:
+		ids, _ := args[0].([]string)
+		results := make([]map[string]interface{}, 0, len(ids))
+		for _, id := range ids {
+			if !p.deleteResult(id) {
+				results = append(results, map[string]interface{}{"id": id, "status": 404, "error": "404 Not Found"})
+				break
+			}
+			results = append(results, map[string]interface{}{"id": id, "status": 200, "error": ""})
+		}
+		return results, nil
+	case internal.ScriptLoggedOut:
+		return p.url.Path == "/i/flow/login" || p.visible("session.loggedOut"), nil
+	case internal.ScriptLoginError:
+		switch p.loginStep {
+		case "challenge":
+			return "challenge", nil
+		case "failed":
+			return "failed", nil
+		}
+		return "", nil
+	case internal.ScriptErrorBanner:
+		return p.visible("search.errorBanner"), nil
+	case internal.ScriptComposeReady:
+		if !p.composeReady() {
+			return nil, nil
+		}
+		return map[string]interface{}{"index": 0}, nil
+	case internal.ScriptPostSent:
+		switch {
+		case p.url.Path != "/compose/post":
+			return map[string]interface{}{"state": "sent", "message": ""}, nil
+		case p.refused != "":
+			return map[string]interface{}{"state": "error", "message": p.refused}, nil
+		}
+		return nil, nil
+	}
+	return nil, fmt.Errorf("the in-memory page does not implement script %q", script.Name)
+}
+
+// store copies v into res the way a script's JSON result would be
+func store(v interface{}, res interface{}) error {
+	if res == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, res)
+}
+
+// HTML describes the page, since an in-memory page has no HTML
+func (p *page) HTML(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return fmt.Sprintf("<!-- in-memory page %s: loggedIn=%t loginStep=%s results=%d menuOpen=%t sheetOpen=%t -->",
+		p.url, p.signedIn(), p.loginStep, len(p.results), p.menuOpen, p.sheetOpen), nil
+}
+
+// Screenshot fails since an in-memory page can't be rendered
+func (p *page) Screenshot(ctx context.Context) ([]byte, error) {
+	return nil, errors.New("the in-memory page can't take screenshots")
+}
+
+// Cookies returns the cookies of the site session the page logged in with, so that the site's APIs
+// can be called over HTTP with the page's session. The page keeps its session itself, so the cookies
+// are the same for every url.
+func (p *page) Cookies(ctx context.Context, url string) ([]internal.Cookie, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.loggedIn {
+		return nil, nil
+	}
+	return []internal.Cookie{
+		{Name: sessionCookie, Value: p.session, Path: "/", HTTPOnly: true},
+		{Name: csrfCookie, Value: p.csrf, Path: "/"},
+		{Name: twidCookie, Value: twid, Path: "/"},
+	}, nil
+}
+
+// SetCookie ignores cookie
+func (p *page) SetCookie(ctx context.Context, cookie internal.Cookie) error {
+	return nil
+}
+
+// Listen calls fn with the page's events. Only a response is emitted for each deleted or posted tweet.
+func (p *page) Listen(fn func(ev interface{})) {
+	p.listenerMu.Lock()
+	defer p.listenerMu.Unlock()
+	p.listeners = append(p.listeners, fn)
+}
+
+// emit sends ev to the page's listeners
+func (p *page) emit(ev interface{}) {
+	p.listenerMu.Lock()
+	defer p.listenerMu.Unlock()
+	for _, fn := range p.listeners {
+		fn(ev)
+	}
+}
+
+// Lost reports whether the page has been closed
+func (p *page) Lost() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// Close closes the page
+func (p *page) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+}
@@ -0,0 +1,700 @@
+// Package fakex is an offline stand-in for the parts of X used to delete tweets. It serves a login
+// flow, the explore and search pages, tweet articles with their "More" menu, the delete confirmation
+// sheet and the composer, and keeps track of which tweets have been deleted and posted, so that
+// TweetDeleter can be run end to end against headless chrome without a network. The same site can
+// also be driven without a browser at all through the in-memory Driver.
+package fakex
+
+import (
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// sessionCookie is the cookie holding the session token of a logged in browser
+	sessionCookie = "auth_token"
+	// csrfCookie is the cookie holding the CSRF token that API calls must repeat in the x-csrf-token header
+	csrfCookie = "ct0"
+	// twidCookie is the cookie holding the id of the logged in account
+	twidCookie = "twid"
+	// timelinePageSize is how many tweets a page of a timeline has when the query doesn't ask for a count
+	timelinePageSize = 20
+	// maxTweetChars is the most characters the composer accepts
+	maxTweetChars = 280
+	// maxTweetMedia is the most files that can be attached to a tweet
+	maxTweetMedia = 4
+	// snowflakeEpoch is the time X's tweet ids count milliseconds from, in milliseconds since the unix epoch
+	snowflakeEpoch = 1288834974657
+)
+
+//go:embed pages.html
+var pagesFS embed.FS
+
+var pages = template.Must(template.ParseFS(pagesFS, "pages.html"))
+
+// graphqlPath matches the path of a GraphQL query or mutation, which includes its query id and name
+var graphqlPath = regexp.MustCompile(`^/i/api/graphql/[^/]+/(\w+)$`)
+
+// searchQuery matches the search queries typed by TweetDeleter
+var searchQuery = regexp.MustCompile(`from:(\S+)\s+since:(\d{4}-\d{2}-\d{2})\s+until:(\d{4}-\d{2}-\d{2})`)
+
+// Tweet is a tweet posted by the fake site's account
+type Tweet struct {
+	ID        string    `json:"id"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+	// RepostOf is the id of the tweet this reposts. Like X's, the site's search doesn't return
+	// reposts, so they can only be found and undone through the API or the GraphQL API.
+	RepostOf string `json:"repost_of,omitempty"`
+	// InReplyTo is the id of the tweet this replies to, if it is a reply
+	InReplyTo string `json:"in_reply_to,omitempty"`
+	// Media are the names of the files attached to a tweet posted through the composer
+	Media []string `json:"media,omitempty"`
+}
+
+// Options configure a fake site
+type Options struct {
+	// Username and Password are the credentials of the site's only account
+	Username string
+	Password string
+	// Tweets are the tweets posted by the account
+	Tweets []Tweet
+	// Likes are the tweets the account has liked, most recently liked first. They are only
+	// served by the API and the GraphQL API.
+	Likes []Tweet
+	// Pinned is the id of the tweet pinned to the top of the account's profile, if any
+	Pinned string
+	// Challenge asks for a verification code after the password is submitted instead of logging in
+	Challenge bool
+	// Lang is the language of the site's UI. Only "en" menu text is rendered, so other
+	// languages can be used to check that tweets aren't deleted when the delete menu item
+	// can't be identified. "en" is used if Lang is empty.
+	Lang string
+	// SearchLag keeps showing tweets in the results of the next search after they're deleted, the way
+	// X's search index lags behind deletions
+	SearchLag bool
+	// LogOutAfter ends every session once that many tweets have been deleted, the way X sometimes logs
+	// the account out part way through a run. Sessions are never ended if LogOutAfter is zero.
+	LogOutAfter int
+	// LogOutAfterPosts ends every session as soon as that many tweets have been posted through the
+	// composer, the way X sometimes posts a tweet but logs the account out before confirming it.
+	// Sessions are never ended by posting if LogOutAfterPosts is zero.
+	LogOutAfterPosts int
+}
+
+// Site is a fake X site. It implements http.Handler and is safe for concurrent use.
+type Site struct {
+	username    string
+	password    string
+	challenge   bool
+	lang        string
+	searchLag   bool
+	logOutAfter int
+	logOutPosts int
+	pinned      string
+
+	mu       sync.Mutex
+	tweets   []Tweet
+	deleted  []string
+	lagging  []Tweet // tweets deleted since the last search, when searchLag is set
+	posted   int64   // number of tweets posted through the composer
+	likes    []Tweet
+	sessions map[string]string // CSRF token of each session
+	// epoch counts how many times every session was ended. In-memory pages remember the epoch they
+	// logged in during, since they keep their session themselves.
+	epoch int
+}
+
+// NewSite creates a fake site from opts
+func NewSite(opts Options) *Site {
+	lang := opts.Lang
+	if lang == "" {
+		lang = "en"
+	}
+
+	tweets := append([]Tweet(nil), opts.Tweets...)
+	sort.SliceStable(tweets, func(i, j int) bool { return tweets[i].CreatedAt.After(tweets[j].CreatedAt) })
+
+	return &Site{
+		username:    opts.Username,
+		password:    opts.Password,
+		challenge:   opts.Challenge,
+		lang:        lang,
+		searchLag:   opts.SearchLag,
+		logOutAfter: opts.LogOutAfter,
+		logOutPosts: opts.LogOutAfterPosts,
+		pinned:      opts.Pinned,
+		tweets:      tweets,
+		likes:       append([]Tweet(nil), opts.Likes...),
+		sessions:    make(map[string]string),
+	}
+}
+
+// NewServer starts serving site on a local port. The caller should call Close on the returned
+// server when finished and pass its URL to TweetDeleter as the base URL.
+func NewServer(site *Site) *httptest.Server {
+	return httptest.NewServer(site)
+}
+
+// GenerateTweets returns n tweets spread evenly between since and until, newest first
+func GenerateTweets(n int, since, until time.Time) []Tweet {
+	tweets := make([]Tweet, 0, n)
+	step := until.Sub(since) / time.Duration(n+1)
+	for i := n; i > 0; i-- {
+		tweets = append(tweets, Tweet{
+			ID:        strconv.FormatInt(1_700_000_000_000_000_000+int64(i), 10),
+			Text:      fmt.Sprintf("tweet number %d", i),
+			CreatedAt: since.Add(step * time.Duration(i)),
+		})
+	}
+	return tweets
+}
+
+// Tweets returns the tweets that haven't been deleted, newest first
+func (s *Site) Tweets() []Tweet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Tweet(nil), s.tweets...)
+}
+
+// Deleted returns the ids of the deleted tweets in the order they were deleted
+func (s *Site) Deleted() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.deleted...)
+}
+
+// Likes returns the tweets the account still likes, most recently liked first
+func (s *Site) Likes() []Tweet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Tweet(nil), s.likes...)
+}
+
+// ServeHTTP serves the fake site's pages and APIs
+func (s *Site) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/":
+		http.Redirect(w, r, "/home", http.StatusFound)
+	case "/i/flow/login":
+		s.render(w, "login", nil)
+	case "/i/api/login":
+		s.handleLogin(w, r)
+	case "/home":
+		if s.requireSession(w, r) {
+			s.render(w, "home", nil)
+		}
+	case "/explore":
+		if s.requireSession(w, r) {
+			s.render(w, "explore", nil)
+		}
+	case "/search":
+		if s.requireSession(w, r) {
+			s.handleSearch(w, r)
+		}
+	case "/compose/post":
+		if s.requireSession(w, r) {
+			s.render(w, "compose", nil)
+		}
+	case "/" + s.username:
+		if s.requireSession(w, r) {
+			s.handleProfile(w)
+		}
+	default:
+		s.handleGraphQL(w, r)
+	}
+}
+
+// handleGraphQL serves the GraphQL queries and mutations TweetDeleter calls
+func (s *Site) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	m := graphqlPath.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		http.NotFound(w, r)
+		return
+	}
+	switch m[1] {
+	case "DeleteTweet":
+		s.handleDelete(w, r)
+	case "CreateTweet":
+		s.handleCreate(w, r)
+	case "DeleteRetweet":
+		s.handleUnrepost(w, r)
+	case "UnfavoriteTweet":
+		s.handleUnlike(w, r)
+	case "UserTweets":
+		s.handleTimeline(w, r, s.Tweets)
+	case "Likes":
+		s.handleTimeline(w, r, s.Likes)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// render writes the page called name
+func (s *Site) render(w http.ResponseWriter, name string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := pages.ExecuteTemplate(w, "layout", struct {
+		Lang string
+		Page string
+		Data interface{}
+	}{s.lang, name, data})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// loggedIn reports whether r comes from a logged in browser
+func (s *Site) loggedIn(r *http.Request) bool {
+	_, ok := s.session(r)
+	return ok
+}
+
+// session returns the CSRF token of the session r belongs to, if it belongs to one
+func (s *Site) session(r *http.Request) (csrf string, ok bool) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	csrf, ok = s.sessions[cookie.Value]
+	return csrf, ok
+}
+
+// requireSession redirects browsers that aren't logged in to the login flow, the way X does.
+// It reports whether the request may continue.
+func (s *Site) requireSession(w http.ResponseWriter, r *http.Request) bool {
+	if s.loggedIn(r) {
+		return true
+	}
+	http.Redirect(w, r, "/i/flow/login?redirect_after_login="+r.URL.Path, http.StatusFound)
+	return false
+}
+
+// handleLogin checks the submitted credentials and starts a session
+func (s *Site) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var creds struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&creds) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	switch s.checkLogin(creds.Username, creds.Password) {
+	case loginFailed:
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "Wrong password!"})
+		return
+	case loginChallenge:
+		writeJSON(w, http.StatusOK, map[string]string{"next": "challenge"})
+		return
+	}
+
+	token, csrf, err := s.startSession()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Like X, the CSRF token is readable by the page's scripts so they can send it back in a header
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: token, Path: "/", HttpOnly: true})
+	http.SetCookie(w, &http.Cookie{Name: csrfCookie, Value: csrf, Path: "/"})
+	http.SetCookie(w, &http.Cookie{Name: twidCookie, Value: twid, Path: "/"})
+	writeJSON(w, http.StatusOK, map[string]string{"next": "/home"})
+}
+
+// twid is the value of the twidCookie, which X escapes
+var twid = url.QueryEscape("u=" + accountID)
+
+// startSession starts a session for the account, returning its token and CSRF token
+func (s *Site) startSession() (token, csrf string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token, csrf = hex.EncodeToString(b[:16]), hex.EncodeToString(b[16:])
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[token] = csrf
+	return token, csrf, nil
+}
+
+// loginResult is the outcome of submitting credentials
+type loginResult int
+
+const (
+	loginOK loginResult = iota
+	loginFailed
+	loginChallenge
+)
+
+// checkLogin checks credentials submitted to the login flow
+func (s *Site) checkLogin(username, password string) loginResult {
+	switch {
+	case username != s.username || password != s.password:
+		return loginFailed
+	case s.challenge:
+		return loginChallenge
+	default:
+		return loginOK
+	}
+}
+
+// handleSearch renders the results of a search. Only queries of the form TweetDeleter types,
+// "from:<user> since:<date> until:<date>", return tweets. since is inclusive and until is exclusive.
+func (s *Site) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	results := struct {
+		Query    string
+		Live     bool
+		Username string
+		Tweets   []Tweet
+	}{Query: q, Live: r.URL.Query().Get("f") == "live", Username: s.username}
+
+	results.Tweets = s.search(q)
+	s.render(w, "search", results)
+}
+
+// handleProfile renders the account's profile
+func (s *Site) handleProfile(w http.ResponseWriter) {
+	tweets := s.profile()
+	profile := struct {
+		Username string
+		Pinned   string
+		Tweets   []Tweet
+	}{Username: s.username, Tweets: tweets}
+	if len(tweets) > 0 && tweets[0].ID == s.pinned {
+		profile.Pinned = s.pinned
+	}
+	s.render(w, "profile", profile)
+}
+
+// profile returns the tweets on the account's profile, newest first after the pinned tweet. Reposts
+// aren't shown, since they are only modelled by the API.
+func (s *Site) profile() []Tweet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var pinned, tweets []Tweet
+	for _, tweet := range s.tweets {
+		switch {
+		case tweet.RepostOf != "":
+		case tweet.ID == s.pinned:
+			pinned = append(pinned, tweet)
+		default:
+			tweets = append(tweets, tweet)
+		}
+	}
+	return append(pinned, tweets...)
+}
+
+// search returns the tweets matching q, newest first. With searchLag, the tweets deleted since the
+// last search are returned too.
+func (s *Site) search(q string) []Tweet {
+	m := searchQuery.FindStringSubmatch(q)
+	if m == nil || m[1] != s.username {
+		return nil
+	}
+	since, sinceErr := time.Parse(time.DateOnly, m[2])
+	until, untilErr := time.Parse(time.DateOnly, m[3])
+	if sinceErr != nil || untilErr != nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var tweets []Tweet
+	for _, tweet := range append(s.lagging, s.tweets...) {
+		if tweet.RepostOf == "" && !tweet.CreatedAt.Before(since) && tweet.CreatedAt.Before(until) {
+			tweets = append(tweets, tweet)
+		}
+	}
+	s.lagging = nil
+	sort.SliceStable(tweets, func(i, j int) bool { return tweets[i].CreatedAt.After(tweets[j].CreatedAt) })
+	return tweets
+}
+
+// signedIn reports whether a session started by an in-memory page during epoch is still valid
+func (s *Site) signedIn(epoch int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return epoch == s.epoch
+}
+
+// currentEpoch returns the epoch sessions started now belong to
+func (s *Site) currentEpoch() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.epoch
+}
+
+// checkCSRF answers API calls that don't come from a logged in browser, or don't send its session's
+// CSRF token in the x-csrf-token header like X requires, with an error. It reports whether the call
+// may continue.
+func (s *Site) checkCSRF(w http.ResponseWriter, r *http.Request) bool {
+	csrf, ok := s.session(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "not logged in"})
+		return false
+	}
+	if r.Header.Get("X-Csrf-Token") != csrf {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "bad csrf token"})
+		return false
+	}
+	return true
+}
+
+// handleDelete deletes a tweet for a logged in browser
+func (s *Site) handleDelete(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Variables struct {
+			TweetID string `json:"tweet_id"`
+		} `json:"variables"`
+	}
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if !s.checkCSRF(w, r) {
+		return
+	}
+
+	if !s.delete(req.Variables.TweetID) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "tweet not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"delete_tweet": map[string]interface{}{}}})
+}
+
+// handleCreate posts a tweet for a logged in browser. Tweets X would refuse are answered with the
+// error X shows for them.
+func (s *Site) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Variables struct {
+			TweetText string   `json:"tweet_text"`
+			Media     []string `json:"media"`
+		} `json:"variables"`
+	}
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if !s.checkCSRF(w, r) {
+		return
+	}
+
+	tweet, refused := s.post(req.Variables.TweetText, req.Variables.Media)
+	if refused != "" {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": refused})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"create_tweet": map[string]interface{}{
+		"tweet_results": map[string]interface{}{"result": map[string]interface{}{"rest_id": tweet.ID}},
+	}}})
+}
+
+// handleUnrepost undoes the account's repost of a tweet for a logged in browser
+func (s *Site) handleUnrepost(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Variables struct {
+			SourceTweetID string `json:"source_tweet_id"`
+		} `json:"variables"`
+	}
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if !s.checkCSRF(w, r) {
+		return
+	}
+
+	if !s.unrepost(req.Variables.SourceTweetID) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "repost not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"unretweet": map[string]interface{}{}}})
+}
+
+// handleUnlike removes the account's like of a tweet for a logged in browser
+func (s *Site) handleUnlike(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Variables struct {
+			TweetID string `json:"tweet_id"`
+		} `json:"variables"`
+	}
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if !s.checkCSRF(w, r) {
+		return
+	}
+
+	if !s.unlike(req.Variables.TweetID) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "like not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"favorite_tweet": "Done"}})
+}
+
+// handleTimeline serves a page of one of the account's timelines, listing the tweets returned by list.
+// Like X's, the cursor of the next page is returned even after the last page, which is empty.
+func (s *Site) handleTimeline(w http.ResponseWriter, r *http.Request, list func() []Tweet) {
+	var variables struct {
+		UserID string `json:"userId"`
+		Count  int    `json:"count"`
+		Cursor string `json:"cursor"`
+	}
+	if r.Method != http.MethodGet || json.Unmarshal([]byte(r.URL.Query().Get("variables")), &variables) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if !s.checkCSRF(w, r) {
+		return
+	}
+	if variables.UserID != accountID {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"errors": []map[string]string{{"message": "User not found"}}})
+		return
+	}
+
+	tweets := list()
+	count, start := variables.Count, 0
+	if count <= 0 {
+		count = timelinePageSize
+	}
+	if variables.Cursor != "" {
+		if n, err := strconv.Atoi(variables.Cursor); err == nil && n >= 0 {
+			start = n
+		}
+	}
+	start = min(start, len(tweets))
+	end := min(start+count, len(tweets))
+
+	entries := make([]interface{}, 0, end-start+1)
+	for _, tweet := range tweets[start:end] {
+		legacy := map[string]interface{}{"created_at": tweet.CreatedAt.Format(time.RubyDate), "full_text": tweet.Text}
+		if tweet.RepostOf != "" {
+			legacy["retweeted_status_result"] = map[string]interface{}{"result": map[string]interface{}{"rest_id": tweet.RepostOf}}
+		}
+		entries = append(entries, map[string]interface{}{
+			"entryId": "tweet-" + tweet.ID,
+			"content": map[string]interface{}{"itemContent": map[string]interface{}{"tweet_results": map[string]interface{}{
+				"result": map[string]interface{}{"rest_id": tweet.ID, "legacy": legacy},
+			}}},
+		})
+	}
+	entries = append(entries, map[string]interface{}{
+		"entryId": "cursor-bottom-" + strconv.Itoa(end),
+		"content": map[string]interface{}{"cursorType": "Bottom", "value": strconv.Itoa(end)},
+	})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"user": map[string]interface{}{"result": map[string]interface{}{
+		"timeline_v2": map[string]interface{}{"timeline": map[string]interface{}{"instructions": []interface{}{
+			map[string]interface{}{"type": "TimelineAddEntries", "entries": entries},
+		}}},
+	}}}})
+}
+
+// post posts a tweet with text and the named media attached. If X would refuse it, because it's empty,
+// too long or repeats one of the account's tweets, the error X shows is returned instead.
+func (s *Site) post(text string, media []string) (Tweet, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case text == "" && len(media) == 0:
+		return Tweet{}, "Your post is empty."
+	case len([]rune(text)) > maxTweetChars:
+		return Tweet{}, "Your post is over the character limit."
+	case len(media) > maxTweetMedia:
+		return Tweet{}, "Please choose up to 4 photos, videos, or GIFs."
+	}
+	for _, tweet := range s.tweets {
+		if text != "" && tweet.Text == text && tweet.RepostOf == "" {
+			return Tweet{}, "Whoops! You already said that."
+		}
+	}
+
+	// Ids are snowflakes, so they grow with the time a tweet was posted like X's do
+	now := time.Now().UTC()
+	s.posted++
+	tweet := Tweet{
+		ID:        strconv.FormatInt((now.UnixMilli()-snowflakeEpoch)<<22|s.posted&0x3fffff, 10),
+		Text:      text,
+		CreatedAt: now,
+		Media:     append([]string(nil), media...),
+	}
+	s.tweets = append([]Tweet{tweet}, s.tweets...)
+	if s.posted == int64(s.logOutPosts) {
+		s.endSessions()
+	}
+	return tweet, ""
+}
+
+// endSessions logs every browser out. s.mu must be held.
+func (s *Site) endSessions() {
+	s.sessions = make(map[string]string)
+	s.epoch++
+}
+
+// delete deletes the tweet with id, reporting whether it existed
+func (s *Site) delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, tweet := range s.tweets {
+		if tweet.ID == id {
+			s.tweets = append(s.tweets[:i], s.tweets[i+1:]...)
+			s.deleted = append(s.deleted, tweet.ID)
+			if s.searchLag {
+				s.lagging = append(s.lagging, tweet)
+			}
+			if len(s.deleted) == s.logOutAfter {
+				s.endSessions()
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// unrepost undoes the account's repost of the tweet with id, reporting whether it was reposted
+func (s *Site) unrepost(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, tweet := range s.tweets {
+		if tweet.RepostOf == id {
+			s.tweets = append(s.tweets[:i], s.tweets[i+1:]...)
+			s.deleted = append(s.deleted, tweet.ID)
+			return true
+		}
+	}
+	return false
+}
+
+// unlike removes the account's like of the tweet with id, reporting whether it was liked
+func (s *Site) unlike(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, tweet := range s.likes {
+		if tweet.ID == id {
+			s.likes = append(s.likes[:i], s.likes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// writeJSON writes v as a JSON response with status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
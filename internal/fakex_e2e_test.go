@@ -0,0 +1,105 @@
+package internal_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"tweetdeleter/internal"
+	"tweetdeleter/internal/fakex"
+)
+
+// A whole purge in headless chrome against the fake site served over HTTP: logging in, searching
+// each week, deleting through the "More" menu and stopping at the empty state
+func TestRunInChromeAgainstFakeX(t *testing.T) {
+	if _, err := internal.FindChrome(); err != nil {
+		t.Skip(err)
+	}
+	since := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2023, 1, 15, 0, 0, 0, 0, time.UTC)
+	tweets := fakex.GenerateTweets(6, since, until)
+	site := fakex.NewSite(fakex.Options{Username: "fake", Password: "secret", Tweets: tweets})
+	server := fakex.NewServer(site)
+	defer server.Close()
+
+	core, logs := observer.New(zap.InfoLevel)
+	td, err := internal.NewTweetDeleter(internal.TweetDeleterOptions{
+		Username:  "fake",
+		Password:  "secret",
+		StartDate: since,
+		EndDate:   until,
+		Logger:    zap.New(core),
+		Headless:  true,
+		BaseURL:   server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := td.Run(); err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+
+	if logs.FilterMessage("successfully logged in").Len() != 1 {
+		t.Error("never logged in")
+	}
+	if empty := logs.FilterMessage("no more tweets to delete from time range").Len(); empty != 2 {
+		t.Errorf("reached the empty state %d times, want once for each of the 2 weeks", empty)
+	}
+	if left := site.Tweets(); len(left) != 0 {
+		t.Errorf("%d tweets left, want 0", len(left))
+	}
+	if deleted := site.Deleted(); len(deleted) != len(tweets) {
+		t.Errorf("deleted %d tweets, want %d", len(deleted), len(tweets))
+	}
+}
+
+// Images on the fake site's pages are only requested from the server when LoadMedia is set
+func TestRunInChromeBlocksImagesUnlessLoadingMedia(t *testing.T) {
+	if _, err := internal.FindChrome(); err != nil {
+		t.Skip(err)
+	}
+	since := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2023, 1, 8, 0, 0, 0, 0, time.UTC)
+
+	for _, loadMedia := range []bool{false, true} {
+		site := fakex.NewSite(fakex.Options{Username: "fake", Password: "secret", Tweets: fakex.GenerateTweets(2, since, until)})
+		var images atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/profile_images/") {
+				images.Add(1)
+			}
+			site.ServeHTTP(w, r)
+		}))
+
+		td, err := internal.NewTweetDeleter(internal.TweetDeleterOptions{
+			Username:  "fake",
+			Password:  "secret",
+			StartDate: since,
+			EndDate:   until,
+			Logger:    zap.NewNop(),
+			Headless:  true,
+			LoadMedia: loadMedia,
+			BaseURL:   server.URL,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = td.Run()
+		server.Close()
+		if err != nil {
+			t.Fatalf("Run() with LoadMedia %v = %v, want nil", loadMedia, err)
+		}
+
+		if n := images.Load(); loadMedia && n == 0 {
+			t.Error("no images were requested with LoadMedia set")
+		} else if !loadMedia && n != 0 {
+			t.Errorf("%d images were requested without LoadMedia, want 0", n)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// webBearerToken is the bearer token X's web client sends with its API calls. It identifies the
+// web client rather than the user, who is identified by the session cookies, and is only used
+// until the token the page itself sends has been seen.
+const webBearerToken = "AAAAAAAAAAAAAAAAAAAAANRILgAAAAAAnNwIzUejRCOuH5E6I8xnZz4puTs%3D1Zv7ttfk8LF81IUq16cHjhLTvJu4FA33AGWWjCpTnA"
+
+// csrfCookie is the cookie holding the CSRF token X expects in the x-csrf-token header
+const csrfCookie = "ct0"
+
+// graphqlOperation is one of the GraphQL queries or mutations X's web client calls. The query id
+// changes whenever X deploys a new version of the operation.
+type graphqlOperation struct {
+	name    string
+	queryID string
+}
+
+var (
+	deleteTweetMutation     = graphqlOperation{name: "DeleteTweet", queryID: "VaenaVgh5q5ih7kvyVjgtg"}
+	deleteRetweetMutation   = graphqlOperation{name: "DeleteRetweet", queryID: "iQtK4dl5hBmXewYZuEOKVw"}
+	unfavoriteTweetMutation = graphqlOperation{name: "UnfavoriteTweet", queryID: "ZYKSe-w7KEslx3JhSIk5LA"}
+	userTweetsQuery         = graphqlOperation{name: "UserTweets", queryID: "V7H0Ap3_Hh2FyS75OCDO3Q"}
+	likesQuery              = graphqlOperation{name: "Likes", queryID: "lIDpu_NWL7_VhimGGt0o6A"}
+)
+
+// timelineFeatures are the feature flags X's web client sends with timeline queries. X rejects
+// queries that leave out any of the flags it expects.
+var timelineFeatures = map[string]bool{
+	"responsive_web_graphql_exclude_directive_enabled":                        true,
+	"verified_phone_label_enabled":                                            false,
+	"creator_subscriptions_tweet_preview_api_enabled":                         true,
+	"responsive_web_graphql_timeline_navigation_enabled":                      true,
+	"responsive_web_graphql_skip_user_profile_image_extensions_enabled":       false,
+	"tweetypie_unmention_optimization_enabled":                                true,
+	"responsive_web_edit_tweet_api_enabled":                                   true,
+	"graphql_is_translatable_rweb_tweet_is_translatable_enabled":              true,
+	"view_counts_everywhere_api_enabled":                                      true,
+	"longform_notetweets_consumption_enabled":                                 true,
+	"responsive_web_twitter_article_tweet_consumption_enabled":                false,
+	"tweet_awards_web_tipping_enabled":                                        false,
+	"freedom_of_speech_not_reach_fetch_enabled":                               true,
+	"standardized_nudges_misinfo":                                             true,
+	"tweet_with_visibility_results_prefer_gql_limited_actions_policy_enabled": true,
+	"longform_notetweets_rich_text_read_enabled":                              true,
+	"longform_notetweets_inline_media_enabled":                                true,
+	"responsive_web_media_download_video_enabled":                             false,
+	"responsive_web_enhance_cards_enabled":                                    false,
+}
+
+// twidCookie is the cookie holding the id of the logged in account, as "u=<id>" escaped
+const twidCookie = "twid"
+
+// timelinePageSize is how many tweets are asked for in each page of a timeline
+const timelinePageSize = 100
+
+// graphqlClient calls X's internal GraphQL API over HTTP with the browser's session
+type graphqlClient struct {
+	site      site
+	http      *http.Client
+	rateLimit *rateLimitMonitor
+
+	mu        sync.Mutex
+	bearer    string
+	userAgent string
+}
+
+// newGraphQLClient creates a client for the API of site, recording throttled calls in rateLimit
+func newGraphQLClient(site site, rateLimit *rateLimitMonitor) *graphqlClient {
+	return &graphqlClient{site: site, http: &http.Client{Timeout: stepTimeout}, rateLimit: rateLimit, bearer: webBearerToken}
+}
+
+// listen watches the API calls made by page for the bearer token and user agent it sends, so that
+// the client's calls look like the page's own
+func (c *graphqlClient) listen(page Page) {
+	page.Listen(func(ev interface{}) {
+		req, ok := ev.(*RequestEvent)
+		if !ok || !strings.HasPrefix(req.URL, c.site.baseURL+"/i/api/") {
+			return
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if bearer, ok := strings.CutPrefix(req.Headers["Authorization"], "Bearer "); ok {
+			c.bearer = bearer
+		}
+		if ua := req.Headers["User-Agent"]; ua != "" {
+			c.userAgent = ua
+		}
+	})
+}
+
+// bearerToken returns the bearer token the page sends, or X's web client token if it hasn't been seen yet
+func (c *graphqlClient) bearerToken() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bearer
+}
+
+// deleteTweet deletes the tweet with id
+func (c *graphqlClient) deleteTweet(ctx context.Context, page Page, id string) error {
+	return c.mutate(ctx, page, deleteTweetMutation, map[string]interface{}{"tweet_id": id, "dark_request": false})
+}
+
+// deleteRetweet undoes the account's repost of the tweet with sourceID
+func (c *graphqlClient) deleteRetweet(ctx context.Context, page Page, sourceID string) error {
+	return c.mutate(ctx, page, deleteRetweetMutation, map[string]interface{}{"source_tweet_id": sourceID, "dark_request": false})
+}
+
+// unfavoriteTweet removes the account's like of the tweet with id
+func (c *graphqlClient) unfavoriteTweet(ctx context.Context, page Page, id string) error {
+	return c.mutate(ctx, page, unfavoriteTweetMutation, map[string]interface{}{"tweet_id": id})
+}
+
+// timelineTweet is a tweet listed in one of an account's timelines
+type timelineTweet struct {
+	ID        string
+	CreatedAt time.Time
+	// RepostOf is the id of the reposted tweet if this is a repost
+	RepostOf string
+}
+
+// timeline returns a page of the timeline q of the account with userID, newest first, starting at
+// cursor. It also returns the cursor of the next page, which is empty once the timeline runs out.
+func (c *graphqlClient) timeline(ctx context.Context, page Page, q graphqlOperation, userID, cursor string) ([]timelineTweet, string, error) {
+	variables := map[string]interface{}{
+		"userId":                 userID,
+		"count":                  timelinePageSize,
+		"includePromotedContent": false,
+		"withV2Timeline":         true,
+	}
+	if cursor != "" {
+		variables["cursor"] = cursor
+	}
+	vars, err := json.Marshal(variables)
+	if err != nil {
+		return nil, "", err
+	}
+	features, err := json.Marshal(timelineFeatures)
+	if err != nil {
+		return nil, "", err
+	}
+	query := url.Values{"variables": {string(vars)}, "features": {string(features)}}
+	data, err := c.call(ctx, page, http.MethodGet, q, query.Encode(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var result struct {
+		Data struct {
+			User struct {
+				Result struct {
+					Timeline struct {
+						Timeline struct {
+							Instructions []struct {
+								Entries []struct {
+									Content struct {
+										CursorType  string `json:"cursorType"`
+										Value       string `json:"value"`
+										ItemContent struct {
+											TweetResults struct {
+												Result struct {
+													RestID string `json:"rest_id"`
+													Legacy struct {
+														CreatedAt             string `json:"created_at"`
+														RetweetedStatusResult struct {
+															Result struct {
+																RestID string `json:"rest_id"`
+															} `json:"result"`
+														} `json:"retweeted_status_result"`
+													} `json:"legacy"`
+												} `json:"result"`
+											} `json:"tweet_results"`
+										} `json:"itemContent"`
+									} `json:"content"`
+								} `json:"entries"`
+							} `json:"instructions"`
+						} `json:"timeline"`
+					} `json:"timeline_v2"`
+				} `json:"result"`
+			} `json:"user"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, "", fmt.Errorf("%s returned an unexpected response: %w", q.name, err)
+	}
+
+	var tweets []timelineTweet
+	var next string
+	for _, instruction := range result.Data.User.Result.Timeline.Timeline.Instructions {
+		for _, entry := range instruction.Entries {
+			if entry.Content.CursorType == "Bottom" {
+				next = entry.Content.Value
+				continue
+			}
+			tweet := entry.Content.ItemContent.TweetResults.Result
+			if tweet.RestID == "" {
+				continue
+			}
+			createdAt, err := time.Parse(time.RubyDate, tweet.Legacy.CreatedAt)
+			if err != nil {
+				return nil, "", fmt.Errorf("%s returned tweet %s with an unexpected date: %w", q.name, tweet.RestID, err)
+			}
+			tweets = append(tweets, timelineTweet{
+				ID:        tweet.RestID,
+				CreatedAt: createdAt,
+				RepostOf:  tweet.Legacy.RetweetedStatusResult.Result.RestID,
+			})
+		}
+	}
+	// X keeps returning a cursor after the last page, which is empty
+	if len(tweets) == 0 {
+		next = ""
+	}
+	return tweets, next, nil
+}
+
+// userID returns the id of the account page is logged in as
+func (c *graphqlClient) userID(ctx context.Context, page Page) (string, error) {
+	cookies, err := page.Cookies(ctx, c.site.baseURL)
+	if err != nil {
+		return "", fmt.Errorf("could not read the session cookies: %w", err)
+	}
+	for _, cookie := range cookies {
+		if cookie.Name != twidCookie {
+			continue
+		}
+		twid, err := url.QueryUnescape(cookie.Value)
+		if err != nil {
+			return "", fmt.Errorf("unexpected %s cookie %q: %w", twidCookie, cookie.Value, err)
+		}
+		if id, ok := strings.CutPrefix(twid, "u="); ok && id != "" {
+			return id, nil
+		}
+		return "", fmt.Errorf("unexpected %s cookie %q", twidCookie, cookie.Value)
+	}
+	return "", fmt.Errorf("the browser has no %s cookie. is it logged in?", twidCookie)
+}
+
+// mutate calls the mutation m with variables using the session cookies of page
+func (c *graphqlClient) mutate(ctx context.Context, page Page, m graphqlOperation, variables map[string]interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"variables": variables, "queryId": m.queryID})
+	if err != nil {
+		return err
+	}
+	_, err = c.call(ctx, page, http.MethodPost, m, "", bytes.NewReader(body))
+	return err
+}
+
+// call calls op using the session cookies of page and returns the response. rawQuery is sent as the
+// URL's query and body as the request's JSON body, if there is one.
+func (c *graphqlClient) call(ctx context.Context, page Page, method string, op graphqlOperation, rawQuery string, body io.Reader) ([]byte, error) {
+	cookies, err := page.Cookies(ctx, c.site.baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the session cookies: %w", err)
+	}
+	var csrf string
+	pairs := make([]string, 0, len(cookies))
+	for _, cookie := range cookies {
+		pairs = append(pairs, cookie.Name+"="+cookie.Value)
+		if cookie.Name == csrfCookie {
+			csrf = cookie.Value
+		}
+	}
+	if csrf == "" {
+		return nil, fmt.Errorf("the browser has no %s cookie. is it logged in?", csrfCookie)
+	}
+
+	u := c.site.baseURL + "/i/api/graphql/" + op.queryID + "/" + op.name
+	if rawQuery != "" {
+		u += "?" + rawQuery
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.bearerToken())
+	c.mu.Lock()
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	c.mu.Unlock()
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Cookie", strings.Join(pairs, "; "))
+	req.Header.Set("X-Csrf-Token", csrf)
+	req.Header.Set("X-Twitter-Auth-Type", "OAuth2Session")
+	req.Header.Set("X-Twitter-Active-User", "yes")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", op.name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		c.rateLimit.throttledResponse(resp)
+		return nil, fmt.Errorf("%w: %s: %s", ErrRateLimited, op.name, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", op.name, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s failed: %s: %s", op.name, resp.Status, bytes.TrimSpace(data))
+	}
+
+	// X reports most failures, like the tweet not existing, as errors in a 200 response
+	var result struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("%s returned an unexpected response: %w", op.name, err)
+	}
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("%s failed: %s", op.name, result.Errors[0].Message)
+	}
+	return data, nil
+}
@@ -0,0 +1,72 @@
+package internal_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"tweetdeleter/internal"
+	"tweetdeleter/internal/fakex"
+)
+
+// HTTP mode deletes the tweets search finds, then lists the account's reposts and likes from its
+// timelines and undoes the ones in the time range, paging through timelines longer than a page
+func TestHTTPModeDeletesTweetsRepostsAndLikes(t *testing.T) {
+	tweets := fakex.GenerateTweets(20, runSince, runUntil)
+	reposts := []fakex.Tweet{
+		{ID: "1", RepostOf: "500", CreatedAt: runSince.Add(time.Hour)},
+		{ID: "2", RepostOf: "501", CreatedAt: runSince.Add(2 * time.Hour)},
+	}
+	repostBefore := fakex.Tweet{ID: "3", RepostOf: "502", CreatedAt: runSince.Add(-time.Hour)}
+	var likes []fakex.Tweet
+	for i := 0; i < 150; i++ {
+		likes = append(likes, fakex.Tweet{ID: strconv.Itoa(600 + i), CreatedAt: runSince.Add(time.Duration(i) * time.Minute)})
+	}
+	likedBefore := fakex.Tweet{ID: "900", CreatedAt: runSince.Add(-time.Hour)}
+	site := fakex.NewSite(fakex.Options{
+		Username: "fake",
+		Password: "secret",
+		Tweets:   append(append(tweets, reposts...), repostBefore),
+		Likes:    append(likes, likedBefore),
+	})
+	server := fakex.NewServer(site)
+	defer server.Close()
+
+	core, logs := observer.New(zap.InfoLevel)
+	td, err := internal.NewTweetDeleter(internal.TweetDeleterOptions{
+		Username:   "fake",
+		Password:   "secret",
+		StartDate:  runSince,
+		EndDate:    runUntil,
+		Logger:     zap.New(core),
+		Driver:     fakex.NewDriver(site),
+		BaseURL:    server.URL,
+		DeleteMode: internal.DeleteModeHTTP,
+		Likes:      true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := td.Run(); err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+
+	checkDeletedOnce(t, site, append(tweets, reposts...))
+	checkReportedCount(t, logs, len(tweets))
+	if left := site.Tweets(); len(left) != 1 || left[0].ID != repostBefore.ID {
+		t.Errorf("tweets left are %v, want only the repost from before the time range", left)
+	}
+	if left := site.Likes(); len(left) != 1 || left[0].ID != likedBefore.ID {
+		t.Errorf("likes left are %v, want only the like of a tweet from before the time range", left)
+	}
+	finished := logs.FilterMessage("finished deleting interactions").All()
+	if len(finished) != 1 {
+		t.Fatalf("logged that interactions finished %d times, want once", len(finished))
+	}
+	if n := finished[0].ContextMap()["interactionsDeleted"]; n != int64(len(reposts)+len(likes)) {
+		t.Errorf("reported %v interactions deleted, want %d", n, len(reposts)+len(likes))
+	}
+}
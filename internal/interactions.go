@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+)
+
+// interactions is the Platform for the account's reposts and, if asked to, its likes in
+// DeleteModeHTTP. X's search doesn't return either, so they're listed from the account's timelines
+// through the GraphQL API with the browser's session, and undone with the mutations X's web client
+// uses.
+type interactions struct {
+	t      *TweetDeleter
+	client *graphqlClient
+	likes  bool
+	userID string
+}
+
+// Login looks up the id of the account the browser is logged in as. The browser the tweets were
+// deleted with is reused, unless its session was lost since.
+func (i *interactions) Login(ctx context.Context) (err error) {
+	if i.t.session == nil || i.userID != "" {
+		if err := i.t.Login(ctx); err != nil {
+			return err
+		}
+	}
+	i.userID, err = i.client.userID(ctx, i.t.session.page)
+	return err
+}
+
+// Enumerate returns the account's reposts made in window, and its likes of tweets posted in window
+// if asked to. The timelines are newest first, so the account's tweets are only paged through until
+// they're older than window, but likes can't be looked up by date so every liked tweet is listed.
+func (i *interactions) Enumerate(ctx context.Context, window Window) ([]Item, error) {
+	var items []Item
+	err := i.page(ctx, userTweetsQuery, func(tweet timelineTweet) bool {
+		if tweet.RepostOf != "" && window.contains(tweet.CreatedAt) {
+			items = append(items, Item{ID: tweet.ID, Kind: ItemRepost, Target: tweet.RepostOf, CreatedAt: tweet.CreatedAt})
+		}
+		return !tweet.CreatedAt.Before(window.Since)
+	})
+	if err != nil || !i.likes {
+		return items, err
+	}
+
+	err = i.page(ctx, likesQuery, func(tweet timelineTweet) bool {
+		if window.contains(tweet.CreatedAt) {
+			items = append(items, Item{ID: tweet.ID, Kind: ItemLike, Target: tweet.ID, CreatedAt: tweet.CreatedAt})
+		}
+		return true
+	})
+	return items, err
+}
+
+// page calls fn with each tweet in the timeline q until the timeline runs out or fn returns false
+func (i *interactions) page(ctx context.Context, q graphqlOperation, fn func(timelineTweet) bool) error {
+	s := i.t.session
+	for cursor := ""; ; {
+		callCtx, cancel := context.WithTimeout(ctx, stepTimeout)
+		tweets, next, err := i.client.timeline(callCtx, s.page, q, i.userID, cursor)
+		cancel()
+		if err != nil {
+			return i.t.checkStepError(s, fmt.Errorf("could not list the account's %s: %w", q.name, err))
+		}
+		for _, tweet := range tweets {
+			if !fn(tweet) {
+				return nil
+			}
+		}
+		if cursor = next; cursor == "" {
+			return nil
+		}
+	}
+}
+
+// Delete undoes a repost or removes a like
+func (i *interactions) Delete(ctx context.Context, item Item) error {
+	s := i.t.session
+	ctx, cancel := context.WithTimeout(ctx, stepTimeout)
+	defer cancel()
+
+	var err error
+	switch item.Kind {
+	case ItemRepost:
+		if err = i.client.deleteRetweet(ctx, s.page, item.Target); err != nil {
+			err = fmt.Errorf("could not undo repost %s: %w", item.ID, err)
+		}
+	case ItemLike:
+		if err = i.client.unfavoriteTweet(ctx, s.page, item.Target); err != nil {
+			err = fmt.Errorf("could not remove like of %s: %w", item.ID, err)
+		}
+	default:
+		err = fmt.Errorf("can't delete %s %s through the account's interactions", item.Kind, item.ID)
+	}
+	if err != nil {
+		return i.t.checkStepError(s, err)
+	}
+	return nil
+}
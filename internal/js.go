@@ -0,0 +1,29 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// evaluateFunction calls the JavaScript function fn with args in the page and stores the
+// result in res, waiting for it to settle if it is a promise. args are marshalled to JSON so
+// selectors don't need to be escaped by hand.
+func evaluateFunction(fn string, res interface{}, args ...interface{}) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		encoded := make([]string, 0, len(args))
+		for _, arg := range args {
+			b, err := json.Marshal(arg)
+			if err != nil {
+				return fmt.Errorf("could not marshal javascript argument: %w", err)
+			}
+			encoded = append(encoded, string(b))
+		}
+		return chromedp.Evaluate(fmt.Sprintf("(%s)(%s)", fn, strings.Join(encoded, ", ")), res,
+			func(p *runtime.EvaluateParams) *runtime.EvaluateParams { return p.WithAwaitPromise(true) }).Do(ctx)
+	})
+}
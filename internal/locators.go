@@ -0,0 +1,250 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// pageReadyTimeout is how long to give X to render an element before giving up
+const pageReadyTimeout = 30 * time.Second
+
+// Locator strategies
+const (
+	// LocateByCSS finds elements matching a CSS selector. This is also how structural
+	// positions, like the nth button in a form, are expressed.
+	LocateByCSS = "css"
+	// LocateByTestID finds elements by their data-testid attribute
+	LocateByTestID = "testid"
+	// LocateByAriaLabel finds elements by their aria-label attribute
+	LocateByAriaLabel = "aria-label"
+	// LocateByText finds the innermost elements whose text is exactly the value
+	LocateByText = "text"
+	// LocateByIcon finds the elements containing an svg path whose path data starts with the value
+	LocateByIcon = "icon"
+)
+
+// locatedAttr marks the element found by a locator so that chromedp can act on it with a plain selector
+const locatedAttr = "data-tweetdeleter-located"
+
+// Locator is a single strategy for finding an element on the page
+type Locator struct {
+	By    string `json:"by"`
+	Value string `json:"value"`
+	// Role, if set, resolves each match to its closest ancestor (or itself) with this ARIA role
+	Role string `json:"role,omitempty"`
+	// Within, if set, only looks for matches inside elements matching this CSS selector
+	Within string `json:"within,omitempty"`
+	// Lang, if set, only uses this locator when the page's language matches, such as "en" or
+	// "pt". This is used for text locators since X's UI text is localized.
+	Lang string `json:"lang,omitempty"`
+}
+
+// String describes the locator for logging
+func (l Locator) String() string {
+	s := l.By + "=" + l.Value
+	if l.Role != "" {
+		s += " role=" + l.Role
+	}
+	if l.Within != "" {
+		s += " within=" + l.Within
+	}
+	if l.Lang != "" {
+		s += " lang=" + l.Lang
+	}
+	return s
+}
+
+// Locators is an ordered list of strategies for finding a logical UI element. The first
+// strategy that matches anything is used.
+type Locators []Locator
+
+// UnmarshalJSON accepts either a list of locators or, for compatibility with version 1
+// selector profiles, a single CSS selector
+func (l *Locators) UnmarshalJSON(data []byte) error {
+	var css string
+	if err := json.Unmarshal(data, &css); err == nil {
+		*l = Locators{{By: LocateByCSS, Value: css}}
+		return nil
+	}
+
+	var locators []Locator
+	if err := json.Unmarshal(data, &locators); err != nil {
+		return err
+	}
+	*l = locators
+	return nil
+}
+
+// validate ensures there is at least one locator and every locator uses a known strategy
+func (l Locators) validate() error {
+	if len(l) == 0 {
+		return fmt.Errorf("no locators")
+	}
+	for i, loc := range l {
+		switch loc.By {
+		case LocateByCSS, LocateByTestID, LocateByAriaLabel, LocateByText, LocateByIcon:
+		default:
+			return fmt.Errorf("locator %d has unknown strategy %q", i, loc.By)
+		}
+		if loc.Value == "" {
+			return fmt.Errorf("locator %d has no value", i)
+		}
+	}
+	return nil
+}
+
+// locateJS is a JavaScript function that finds the elements matched by the first locator in a
+// list that matches anything, optionally only looking inside the elements matching scope. It
+// returns the index of the locator used along with the matched elements, or null if nothing matched.
+// Locators for a language other than the page's are skipped.
+const locateJS = `(locators, scope) => {
+	const roots = scope ? Array.from(document.querySelectorAll(scope)) : [document];
+	const attr = (name, value) => "[" + name + '="' + CSS.escape(value) + '"]';
+	const pageLang = (document.documentElement.lang || "").toLowerCase();
+
+	for (let i = 0; i < locators.length; i++) {
+		const l = locators[i];
+		if (l.lang && pageLang !== l.lang.toLowerCase() && !pageLang.startsWith(l.lang.toLowerCase() + "-")) {
+			continue;
+		}
+		const within = l.within ? roots.flatMap((root) => Array.from(root.querySelectorAll(l.within))) : roots;
+
+		let found = [];
+		for (const root of within) {
+			switch (l.by) {
+			case "css":
+				found.push(...root.querySelectorAll(l.value));
+				break;
+			case "testid":
+				found.push(...root.querySelectorAll(attr("data-testid", l.value)));
+				break;
+			case "aria-label":
+				found.push(...root.querySelectorAll(attr("aria-label", l.value)));
+				break;
+			case "text": {
+				const walker = document.createTreeWalker(root, NodeFilter.SHOW_TEXT);
+				for (let node = walker.nextNode(); node !== null; node = walker.nextNode()) {
+					const el = node.parentElement;
+					if (el !== null && node.data.trim() === l.value && el.textContent.trim() === l.value) {
+						found.push(el);
+					}
+				}
+				break;
+			}
+			case "icon":
+				for (const path of root.querySelectorAll("svg path")) {
+					if ((path.getAttribute("d") || "").startsWith(l.value)) {
+						found.push(path.closest("svg").parentElement ?? path);
+					}
+				}
+				break;
+			}
+		}
+
+		if (l.role) {
+			found = found.map((el) => el.closest(attr("role", l.role))).filter((el) => el !== null);
+		}
+		found = Array.from(new Set(found));
+		if (found.length > 0) {
+			return { index: i, elements: found };
+		}
+	}
+	return null;
+}`
+
+// withLocate wraps the JavaScript function fn so that it can call locateJS as locate
+func withLocate(fn string) string {
+	return fmt.Sprintf(`(...args) => {
+	const locate = %s;
+	return (%s)(...args);
+}`, locateJS, fn)
+}
+
+// located returns the selector for the element most recently found by a chromePage with name
+func located(name string) string {
+	return fmt.Sprintf("[%s=%q]", locatedAttr, name)
+}
+
+// screen is what the page objects are built on: the selector profile used to find elements
+// and the logger used to warn when an element was only found by a fallback locator
+type screen struct {
+	selectors *SelectorProfile
+	logger    *zap.Logger
+}
+
+// locate waits for the UI element called name to appear, looking only inside the elements matching
+// scope if it isn't empty, so that it can be acted on by name. A warning is logged when the element
+// was only found by one of its fallback locators.
+func (sc screen) locate(name string, locators Locators, scope string) step {
+	return func(ctx context.Context, p Page) error {
+		index, err := p.Find(ctx, name, locators, scope, pageReadyTimeout)
+		if err != nil {
+			return err
+		}
+
+		sc.logFallback(name, locators, index)
+		return nil
+	}
+}
+
+// logFallback warns when an element had to be found by one of its fallback locators,
+// which usually means X has changed its UI and the selector profile should be updated
+func (sc screen) logFallback(name string, locators Locators, index int) {
+	if index <= 0 || index >= len(locators) {
+		return
+	}
+	sc.logger.Warn("found element using fallback locator",
+		zap.String("element", name),
+		zap.Stringer("primary", locators[0]),
+		zap.Stringer("fallback", locators[index]))
+}
+
+// clickLocated clicks the element most recently found by locate with name
+func clickLocated(name string) step {
+	return func(ctx context.Context, p Page) error {
+		return p.Click(ctx, name)
+	}
+}
+
+// click finds the UI element called name and clicks it
+func (sc screen) click(name string, locators Locators) step {
+	return steps(
+		sc.locate(name, locators, ""),
+		clickLocated(name),
+	)
+}
+
+// sendKeys finds the UI element called name, focuses it and types text into it
+func (sc screen) sendKeys(name string, locators Locators, text string) step {
+	return steps(
+		sc.click(name, locators),
+		func(ctx context.Context, p Page) error {
+			return p.Type(ctx, name, text)
+		},
+	)
+}
+
+// waitVisible waits until the UI element called name is visible
+func (sc screen) waitVisible(name string, locators Locators) step {
+	return steps(
+		sc.locate(name, locators, ""),
+		func(ctx context.Context, p Page) error {
+			return p.WaitVisible(ctx, name)
+		},
+	)
+}
+
+// count stores the number of elements matched by the first of locators that matches anything in n,
+// looking only inside the elements matching scope if it isn't empty. Unlike locate it doesn't wait.
+func count(name string, locators Locators, scope string, n *int) step {
+	return evaluate(countScript, n, name, locators, scope)
+}
+
+// countScript implements ScriptCount
+var countScript = Script{Name: ScriptCount, Source: withLocate(`(name, locators, scope) => {
+	return locate(locators, scope)?.elements.length ?? 0;
+}`)}
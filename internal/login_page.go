@@ -0,0 +1,54 @@
+package internal
+
+// loginPage is X's login flow, which asks for the username and password on separate steps
+type loginPage struct {
+	screen
+	site site
+}
+
+// loginPage returns the login flow of the site tweets are deleted from
+func (t *TweetDeleter) loginPage() loginPage {
+	return loginPage{screen: t.screen, site: t.site}
+}
+
+// open loads the start of the login flow
+func (l loginPage) open() step {
+	return navigate(l.site.loginURL())
+}
+
+// enterUsername types username into the first step of the flow and moves on to the password step
+func (l loginPage) enterUsername(username string) step {
+	return steps(
+		l.sendKeys("login.usernameInput", l.selectors.Login.UsernameInput, username),
+		l.click("login.nextButton", l.selectors.Login.NextButton),
+	)
+}
+
+// enterPassword types password into the password step and submits it
+func (l loginPage) enterPassword(password string) step {
+	return steps(
+		l.sendKeys("login.passwordInput", l.selectors.Login.PasswordInput, password),
+		l.click("login.submitButton", l.selectors.Login.SubmitButton),
+	)
+}
+
+// waitLoggedIn waits until X shows its logged in UI
+func (l loginPage) waitLoggedIn() step {
+	return l.waitVisible("session.loggedIn", l.selectors.Session.LoggedIn)
+}
+
+// logIn goes through the whole login flow
+func (l loginPage) logIn(username, password string) step {
+	return steps(
+		l.open(),
+		l.enterUsername(username),
+		l.enterPassword(password),
+		l.waitLoggedIn(),
+	)
+}
+
+// loginError stores why logging in failed in reason: "locked", "challenge", "failed" or "" if
+// the page doesn't say
+func (l loginPage) loginError(reason *string) step {
+	return evaluate(loginErrorScript, reason, l.selectors.Login.ChallengeInput, l.selectors.Login.ErrorMessage)
+}
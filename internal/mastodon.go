@@ -0,0 +1,425 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// mastodonPageSize is how many statuses are requested per page, which is the most Mastodon returns
+	mastodonPageSize = "40"
+	// mastodonMaxChars is how long statuses can be on Mastodon servers that haven't raised the limit
+	mastodonMaxChars = 500
+	// mastodonMaxMedia is how many media files can be attached to a status
+	mastodonMaxMedia = 4
+)
+
+// linkNextMaxID matches the max_id of the next page in a Link header
+var linkNextMaxID = regexp.MustCompile(`<[^>]*[?&]max_id=([^&>]+)[^>]*>;\s*rel="next"`)
+
+// MastodonOptions configure a MastodonDeleter
+type MastodonOptions struct {
+	// Server is the scheme and host of the account's Mastodon server, such as https://mastodon.social
+	// or a local mock served by the fakemastodon package
+	Server string
+	// AccessToken is an access token for the account with the read:accounts, read:statuses,
+	// read:favourites, write:statuses and write:favourites scopes, such as one created under
+	// Preferences → Development
+	AccessToken string
+	StartDate   time.Time
+	EndDate     time.Time
+	Logger      *zap.Logger
+	// Likes also removes the account's favourites of statuses posted in the time range
+	Likes bool
+}
+
+// MastodonDeleter deletes the statuses a Mastodon account posted in a time range, undoes its
+// boosts, and optionally removes its favourites, through Mastodon's REST API
+type MastodonDeleter struct {
+	logger    *zap.Logger
+	client    *mastodonClient
+	startDate time.Time
+	endDate   time.Time
+	rateLimit *rateLimitMonitor
+}
+
+// NewMastodonDeleter creates a MastodonDeleter configured by opts
+func NewMastodonDeleter(opts MastodonOptions) (*MastodonDeleter, error) {
+	rateLimit := &rateLimitMonitor{}
+	client, err := newMastodonClient(opts, opts.Logger, rateLimit)
+	if err != nil {
+		return nil, err
+	}
+	return &MastodonDeleter{
+		logger:    opts.Logger,
+		client:    client,
+		startDate: opts.StartDate,
+		endDate:   opts.EndDate,
+		rateLimit: rateLimit,
+	}, nil
+}
+
+// Run deletes the account's statuses and boosts in the time range, and its favourites of statuses
+// posted in the time range if asked to. Throttled calls are retried once the server is willing to
+// talk to us again.
+func (m *MastodonDeleter) Run() error {
+	p := &purge{
+		platform:  m.client,
+		logger:    m.logger,
+		rateLimit: m.rateLimit,
+		endDate:   m.endDate,
+		noun:      "statuses",
+		progress:  progress{since: m.startDate},
+	}
+	return p.run(context.Background())
+}
+
+// mastodonAccount is the account the access token belongs to
+type mastodonAccount struct {
+	ID   string `json:"id"`
+	Acct string `json:"acct"`
+}
+
+// mastodonStatus is a status returned by the API. Boosts are statuses of their own that hold the
+// boosted status in Reblog.
+type mastodonStatus struct {
+	ID        string          `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Reblog    *mastodonStatus `json:"reblog"`
+}
+
+// mastodonAttachment is an uploaded media file. Its URL is empty until the server has processed it.
+type mastodonAttachment struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+// mastodonError is the body of an error response from the API
+type mastodonError struct {
+	Code    int    `json:"-"`
+	Status  string `json:"-"`
+	Message string `json:"error"`
+}
+
+func (e *mastodonError) Error() string {
+	if e.Message == "" {
+		return e.Status
+	}
+	return e.Status + ": " + e.Message
+}
+
+// mastodonClient calls the endpoints of Mastodon's REST API used to find and delete an account's
+// statuses. It is the Platform for Mastodon, and a mirror tweets can be copied to.
+type mastodonClient struct {
+	server    string
+	token     string
+	likes     bool
+	http      *http.Client
+	rateLimit *rateLimitMonitor
+	logger    *zap.Logger
+	accountID string // id of the account the token belongs to, looked up by Login
+}
+
+// newMastodonClient creates a client for the account configured by opts, recording throttled calls in
+// rateLimit
+func newMastodonClient(opts MastodonOptions, logger *zap.Logger, rateLimit *rateLimitMonitor) (*mastodonClient, error) {
+	if opts.AccessToken == "" {
+		return nil, errors.New("an access token is required to use the mastodon api")
+	}
+	u, err := url.Parse(opts.Server)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid mastodon server %q", opts.Server)
+	}
+	return &mastodonClient{
+		server:    strings.TrimSuffix(opts.Server, "/"),
+		token:     opts.AccessToken,
+		likes:     opts.Likes,
+		http:      &http.Client{Timeout: stepTimeout},
+		rateLimit: rateLimit,
+		logger:    logger,
+	}, nil
+}
+
+// Login looks up the account the access token belongs to
+func (c *mastodonClient) Login(ctx context.Context) error {
+	account, err := c.verifyCredentials(ctx)
+	if err != nil {
+		return err
+	}
+	c.accountID = account.ID
+	c.logger.Info("successfully logged in", zap.String("username", account.Acct), zap.String("accountID", account.ID))
+	return nil
+}
+
+// Enumerate returns the account's statuses and boosts in window, and its favourites of statuses posted
+// in window if asked to. Statuses are listed newest first, so listing stops at the first one before
+// window. Favourites are listed in the order they were made, not by date, so every favourite is listed.
+func (c *mastodonClient) Enumerate(ctx context.Context, window Window) ([]Item, error) {
+	var items []Item
+	for maxID, done := "", false; !done; {
+		page, err := c.statuses(ctx, c.accountID, maxID)
+		if err != nil {
+			return nil, err
+		}
+		done = len(page) == 0
+		for _, status := range page {
+			if status.CreatedAt.Before(window.Since) {
+				done = true
+				break
+			}
+			if !status.CreatedAt.Before(window.Until) {
+				continue
+			}
+			item := Item{ID: status.ID, Kind: ItemPost, CreatedAt: status.CreatedAt}
+			if status.Reblog != nil {
+				item.Kind, item.Target = ItemRepost, status.Reblog.ID
+			}
+			items = append(items, item)
+		}
+		if len(page) > 0 {
+			maxID = page[len(page)-1].ID
+		}
+	}
+	if !c.likes {
+		return items, nil
+	}
+
+	for maxID, done := "", false; !done; {
+		page, next, err := c.favourites(ctx, maxID)
+		if err != nil {
+			return nil, err
+		}
+		for _, status := range page {
+			if !status.CreatedAt.Before(window.Since) && status.CreatedAt.Before(window.Until) {
+				items = append(items, Item{ID: status.ID, Kind: ItemLike, Target: status.ID, CreatedAt: status.CreatedAt})
+			}
+		}
+		done, maxID = next == "" || len(page) == 0, next
+	}
+	return items, nil
+}
+
+// Delete deletes a status, undoes a boost or removes a favourite
+func (c *mastodonClient) Delete(ctx context.Context, item Item) error {
+	switch item.Kind {
+	case ItemRepost:
+		if err := c.unreblog(ctx, item.Target); err != nil {
+			return fmt.Errorf("could not undo boost %s: %w", item.ID, err)
+		}
+	case ItemLike:
+		if err := c.unfavourite(ctx, item.Target); err != nil {
+			return fmt.Errorf("could not remove favourite of %s: %w", item.ID, err)
+		}
+	default:
+		if err := c.deleteStatus(ctx, item.ID); err != nil {
+			return fmt.Errorf("could not delete status %s: %w", item.ID, err)
+		}
+	}
+	return nil
+}
+
+// verifyCredentials returns the account the access token belongs to. A rejected token is reported as
+// ErrLoginFailed and a suspended or disabled account as ErrAccountLocked.
+func (c *mastodonClient) verifyCredentials(ctx context.Context) (mastodonAccount, error) {
+	var account mastodonAccount
+	_, err := c.do(ctx, http.MethodGet, "/api/v1/accounts/verify_credentials", nil, &account)
+
+	var merr *mastodonError
+	if errors.As(err, &merr) {
+		switch merr.Code {
+		case http.StatusUnauthorized:
+			return account, fmt.Errorf("%w: %w", ErrLoginFailed, err)
+		case http.StatusForbidden:
+			return account, fmt.Errorf("%w: %w", ErrAccountLocked, err)
+		}
+	}
+	return account, err
+}
+
+// statuses returns a page of the statuses and boosts posted by accountID before the status with maxID,
+// newest first. The newest page is returned if maxID is empty.
+func (c *mastodonClient) statuses(ctx context.Context, accountID, maxID string) ([]mastodonStatus, error) {
+	q := url.Values{"limit": {mastodonPageSize}}
+	if maxID != "" {
+		q.Set("max_id", maxID)
+	}
+	var page []mastodonStatus
+	_, err := c.do(ctx, http.MethodGet, "/api/v1/accounts/"+url.PathEscape(accountID)+"/statuses", q, &page)
+	return page, err
+}
+
+// favourites returns a page of the statuses the account has favourited, most recently favourited first,
+// along with the max_id of the next page, which is empty on the last page. Favourites are paged by an
+// internal id that is only given in the Link header.
+func (c *mastodonClient) favourites(ctx context.Context, maxID string) ([]mastodonStatus, string, error) {
+	q := url.Values{"limit": {mastodonPageSize}}
+	if maxID != "" {
+		q.Set("max_id", maxID)
+	}
+	var page []mastodonStatus
+	header, err := c.do(ctx, http.MethodGet, "/api/v1/favourites", q, &page)
+	if err != nil {
+		return nil, "", err
+	}
+	var next string
+	if m := linkNextMaxID.FindStringSubmatch(header.Get("Link")); m != nil {
+		next, _ = url.QueryUnescape(m[1])
+	}
+	return page, next, nil
+}
+
+// deleteStatus deletes the status with id
+func (c *mastodonClient) deleteStatus(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodDelete, "/api/v1/statuses/"+url.PathEscape(id), nil, nil)
+	return err
+}
+
+// unreblog undoes the account's boost of the status with id
+func (c *mastodonClient) unreblog(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodPost, "/api/v1/statuses/"+url.PathEscape(id)+"/unreblog", nil, nil)
+	return err
+}
+
+// unfavourite removes the account's favourite of the status with id
+func (c *mastodonClient) unfavourite(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodPost, "/api/v1/statuses/"+url.PathEscape(id)+"/unfavourite", nil, nil)
+	return err
+}
+
+// crossPost posts a copy of tweet as a public status with up to four of the tweet's media attached. The
+// tweet's id is sent as the status's idempotency key, so that the server doesn't post it twice if a
+// confirmation is lost and the copy is posted again.
+func (c *mastodonClient) crossPost(ctx context.Context, tweet archivedTweet, media []archivedMedia) (string, error) {
+	var mediaIDs []string
+	for _, m := range media[:min(len(media), mastodonMaxMedia)] {
+		id, err := c.uploadMedia(ctx, m)
+		if err != nil {
+			return "", err
+		}
+		mediaIDs = append(mediaIDs, id)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"status":     crossPostText(tweet, mastodonMaxChars),
+		"media_ids":  mediaIDs,
+		"visibility": "public",
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.server+"/api/v1/statuses", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", "tweetdeleter-"+tweet.ID)
+
+	var status mastodonStatus
+	if _, err := c.send(req, &status); err != nil {
+		return "", err
+	}
+	return status.ID, nil
+}
+
+// uploadMedia uploads a file to attach to a status and returns its id. The server may process large
+// files in the background, in which case uploadMedia waits until they're ready to be attached.
+func (c *mastodonClient) uploadMedia(ctx context.Context, media archivedMedia) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, media.Name))
+	header.Set("Content-Type", media.MimeType)
+	part, err := form.CreatePart(header)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(media.Data); err != nil {
+		return "", err
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.server+"/api/v2/media", &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	var attachment mastodonAttachment
+	if _, err := c.send(req, &attachment); err != nil {
+		return "", err
+	}
+	for attachment.URL == "" {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(time.Second):
+		}
+		if _, err := c.do(ctx, http.MethodGet, "/api/v1/media/"+url.PathEscape(attachment.ID), nil, &attachment); err != nil {
+			return "", err
+		}
+	}
+	return attachment.ID, nil
+}
+
+// do calls the endpoint at path and decodes its response into res, returning the response's headers
+func (c *mastodonClient) do(ctx context.Context, method, path string, query url.Values, res interface{}) (http.Header, error) {
+	u := c.server + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.send(req, res)
+}
+
+// send sends req with the access token and decodes its response into res, returning the response's
+// headers. Error responses are returned as *mastodonError.
+func (c *mastodonClient) send(req *http.Request, res interface{}) (http.Header, error) {
+	method, path := req.Method, req.URL.Path
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		c.rateLimit.throttledResponse(resp)
+		return nil, fmt.Errorf("%w: %s %s: %s", ErrRateLimited, method, path, resp.Status)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		merr := &mastodonError{Code: resp.StatusCode, Status: resp.Status}
+		if json.Unmarshal(data, merr) != nil {
+			merr.Message = strings.TrimSpace(string(data))
+		}
+		return nil, fmt.Errorf("%s %s failed: %w", method, path, merr)
+	}
+	if res == nil {
+		return resp.Header, nil
+	}
+	if err := json.Unmarshal(data, res); err != nil {
+		return nil, fmt.Errorf("%s %s returned an unexpected response: %w", method, path, err)
+	}
+	return resp.Header, nil
+}
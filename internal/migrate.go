@@ -0,0 +1,176 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// MigrateOptions configure copying each tweet to a Mastodon or Bluesky account before it is deleted
+type MigrateOptions struct {
+	// Archive is the X data archive the text, media and date of each tweet are read from. It can be
+	// the downloaded zip file or the directory it was extracted to.
+	Archive string
+	// Mastodon is the account tweets are copied to. Only Server and AccessToken are used.
+	Mastodon *MastodonOptions
+	// Bluesky is the account tweets are copied to. Only Handle, AppPassword and PDSURL are used.
+	Bluesky *BlueskyOptions
+}
+
+// mirror is an account on another platform that tweets are copied to
+type mirror interface {
+	// Login starts a session on the platform
+	Login(ctx context.Context) error
+	// crossPost posts a copy of tweet with media attached and returns the id of the copy once the
+	// platform has confirmed it
+	crossPost(ctx context.Context, tweet archivedTweet, media []archivedMedia) (string, error)
+}
+
+// migration is a Platform that copies each tweet to a mirror before the wrapped Platform deletes it.
+// Tweets that aren't in the archive are left alone, since there is nothing to copy, and so are replies
+// and reposts in the archive, since they can't be posted the way they originally were. Reposts and
+// likes found on X, which aren't the account's own content, aren't copied.
+type migration struct {
+	Platform
+	mirror  mirror
+	archive *tweetArchive
+	logger  *zap.Logger
+	copies  map[string]string // id of the copy of each tweet copied so far
+	kept    map[string]bool   // ids of the tweets left alone because they can't be copied
+}
+
+// newMigration creates a migration configured by opts for the tweets posted between since and until.
+// The Platform it wraps is set by the caller.
+func newMigration(opts MigrateOptions, since, until time.Time, logger *zap.Logger, rateLimit *rateLimitMonitor) (*migration, error) {
+	var m mirror
+	var err error
+	switch {
+	case (opts.Mastodon == nil) == (opts.Bluesky == nil):
+		return nil, errors.New("exactly one of a mastodon or bluesky account is required to migrate tweets to")
+	case opts.Mastodon != nil:
+		m, err = newMastodonClient(*opts.Mastodon, logger, rateLimit)
+	default:
+		m, err = newBlueskyClient(*opts.Bluesky, logger, rateLimit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := openTweetArchive(opts.Archive)
+	if err != nil {
+		return nil, err
+	}
+	uncopyable := 0
+	for _, tweet := range archive.between(since, until) {
+		if !copyable(tweet) {
+			uncopyable++
+		}
+	}
+	logger.Info("read tweet archive", zap.String("archive", opts.Archive),
+		zap.Int("tweets", len(archive.tweets)), zap.Int("repliesAndRepostsKept", uncopyable))
+	return &migration{
+		mirror:  m,
+		archive: archive,
+		logger:  logger,
+		copies:  make(map[string]string),
+		kept:    make(map[string]bool),
+	}, nil
+}
+
+// Login logs into X and then into the account tweets are copied to
+func (m *migration) Login(ctx context.Context) error {
+	if err := m.Platform.Login(ctx); err != nil {
+		return err
+	}
+	return m.mirror.Login(ctx)
+}
+
+// Enumerate returns the items in window, leaving out tweets that aren't in the archive and replies
+// and reposts that can't be copied
+func (m *migration) Enumerate(ctx context.Context, window Window) ([]Item, error) {
+	items, err := m.Platform.Enumerate(ctx, window)
+	if err != nil {
+		return nil, err
+	}
+	pending := items[:0]
+	for _, item := range items {
+		if item.Kind != ItemPost {
+			pending = append(pending, item)
+			continue
+		}
+		switch tweet, ok := m.archive.tweet(item.ID); {
+		case !ok:
+			m.keep(item.ID, "tweet isn't in the archive. keeping it")
+		case !copyable(tweet):
+			m.keep(item.ID, "tweet is a reply or repost, which can't be copied. keeping it")
+		default:
+			pending = append(pending, item)
+		}
+	}
+	return pending, nil
+}
+
+// keep logs why the tweet with id is left alone the first time it is enumerated
+func (m *migration) keep(id, why string) {
+	if !m.kept[id] {
+		m.logger.Warn(why, zap.String("tweetID", id))
+		m.kept[id] = true
+	}
+}
+
+// Delete copies a tweet to the mirror and deletes it once the copy is confirmed. Tweets that were
+// already copied, such as when deleting them failed, aren't copied again.
+func (m *migration) Delete(ctx context.Context, item Item) error {
+	if item.Kind == ItemPost && m.copies[item.ID] == "" {
+		tweet, _ := m.archive.tweet(item.ID)
+		media, err := m.archive.media(tweet)
+		if err != nil {
+			return err
+		}
+		id, err := m.mirror.crossPost(ctx, tweet, media)
+		if err != nil {
+			return fmt.Errorf("could not copy tweet %s: %w", item.ID, err)
+		}
+		m.copies[item.ID] = id
+		m.logger.Info("copied tweet", zap.String("tweetID", item.ID), zap.String("copy", id))
+	}
+	return m.Platform.Delete(ctx, item)
+}
+
+// copyable reports whether tweet can be copied, which replies and reposts can't be the way they were
+// originally posted
+func copyable(tweet archivedTweet) bool {
+	return !tweet.Repost && tweet.InReplyTo == ""
+}
+
+// close closes the archive
+func (m *migration) close() error {
+	return m.archive.close()
+}
+
+// crossPostText returns the text of the copy of tweet, which mentions when the tweet was originally
+// posted, cut short so that the copy is at most limit characters
+func crossPostText(tweet archivedTweet, limit int) string {
+	return withNote(tweet.Text, "(Originally posted on X on "+tweet.CreatedAt.Format("2 January 2006")+")", limit)
+}
+
+// withNote returns text followed by note in a paragraph of its own, with text cut short so that the
+// result is at most limit characters
+func withNote(text, note string, limit int) string {
+	if text == "" {
+		return note
+	}
+	return truncate(text, limit-len([]rune(note))-2) + "\n\n" + note
+}
+
+// truncate cuts text short with an ellipsis if it is longer than limit characters
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(append(runes[:max(limit-1, 0)], '…'))
+}
@@ -0,0 +1,66 @@
+package internal_test
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"tweetdeleter/internal"
+	"tweetdeleter/internal/fakemastodon"
+	"tweetdeleter/internal/fakex"
+)
+
+// Tweets are copied to Mastodon before they're deleted, while replies, which can't be copied, and
+// tweets missing from the archive are kept on X
+func TestMigrateCopiesTweetsBeforeDeleting(t *testing.T) {
+	tweets := fakex.GenerateTweets(10, runSince, runUntil)
+	reply := fakex.Tweet{ID: "1", Text: "@someone a reply", CreatedAt: runSince.Add(time.Hour), InReplyTo: "900"}
+	unarchived := fakex.Tweet{ID: "2", Text: "posted after downloading the archive", CreatedAt: runSince.Add(2 * time.Hour)}
+	archive := t.TempDir()
+	if err := fakex.WriteArchive(archive, append(tweets, reply), nil); err != nil {
+		t.Fatal(err)
+	}
+	site := fakex.NewSite(fakex.Options{Username: "fake", Password: "secret", Tweets: append(tweets, reply, unarchived)})
+	instance := fakemastodon.NewInstance(fakemastodon.Options{Username: "fake", AccessToken: "token"})
+	server := fakemastodon.NewServer(instance)
+	defer server.Close()
+
+	core, logs := observer.New(zap.InfoLevel)
+	td, err := internal.NewTweetDeleter(internal.TweetDeleterOptions{
+		Username:  "fake",
+		Password:  "secret",
+		StartDate: runSince,
+		EndDate:   runUntil,
+		Logger:    zap.New(core),
+		Driver:    fakex.NewDriver(site),
+		Migrate: internal.MigrateOptions{
+			Archive:  archive,
+			Mastodon: &internal.MastodonOptions{Server: server.URL, AccessToken: "token"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := td.Run(); err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+
+	checkDeletedOnce(t, site, tweets)
+	if copies := instance.Statuses(); len(copies) != len(tweets) {
+		t.Errorf("copied %d tweets, want %d", len(copies), len(tweets))
+	}
+	left := make(map[string]bool)
+	for _, tweet := range site.Tweets() {
+		left[tweet.ID] = true
+	}
+	if len(left) != 2 || !left[reply.ID] || !left[unarchived.ID] {
+		t.Errorf("tweets left are %v, want the reply and the tweet missing from the archive", left)
+	}
+	read := logs.FilterMessage("read tweet archive").All()
+	if len(read) != 1 || read[0].ContextMap()["repliesAndRepostsKept"] != int64(1) {
+		t.Errorf("didn't log that one reply is kept: %v", read)
+	}
+	checkReportedCount(t, logs, len(tweets))
+}
@@ -0,0 +1,291 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultAuthorizeURL is where X asks the account owner to authorize the app
+	defaultAuthorizeURL = "https://x.com/i/oauth2/authorize"
+	// defaultCallbackAddr is where the local callback server listens for the authorization code
+	defaultCallbackAddr = "127.0.0.1:8723"
+	// authorizeTimeout is how long to wait for the account owner to authorize the app
+	authorizeTimeout = 5 * time.Minute
+	// tokenExpiryMargin refreshes access tokens a little before X expires them
+	tokenExpiryMargin = time.Minute
+)
+
+// oauthScopes are the scopes needed to find and delete the account's tweets, reposts and likes.
+// offline.access is needed for a refresh token.
+var oauthScopes = []string{"tweet.read", "tweet.write", "users.read", "like.read", "like.write", "offline.access"}
+
+// oauthToken is an access token along with the refresh token used to renew it
+type oauthToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// expired reports whether the token has expired or is about to
+func (t *oauthToken) expired() bool {
+	return !t.ExpiresAt.IsZero() && time.Now().Add(tokenExpiryMargin).After(t.ExpiresAt)
+}
+
+// oauthClient authorizes the app to act on the account through OAuth 2.0 authorization code
+// flow with PKCE, and keeps its access token fresh
+type oauthClient struct {
+	clientID     string
+	clientSecret string
+	authorizeURL string
+	tokenURL     string
+	callbackAddr string
+	tokenFile    string
+	openURL      func(authURL string) error
+	http         *http.Client
+	logger       *zap.Logger
+
+	mu    sync.Mutex
+	token *oauthToken
+}
+
+// accessToken returns a valid access token, loading it from the token file, refreshing it or asking
+// the account owner to authorize the app as needed. A token that X rejected can be passed as
+// rejected to force it to be refreshed.
+func (c *oauthClient) accessToken(ctx context.Context, rejected string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token == nil && c.tokenFile != "" {
+		token, err := loadOAuthToken(c.tokenFile)
+		if err != nil {
+			return "", err
+		}
+		c.token = token
+	}
+	if c.token != nil && (c.token.expired() || c.token.AccessToken == rejected) {
+		token, err := c.refresh(ctx)
+		if err != nil {
+			c.logger.Warn("could not refresh the access token. authorizing again", zap.Error(err))
+		} else {
+			c.save(token)
+		}
+		c.token = token
+	}
+	if c.token == nil {
+		token, err := c.authorize(ctx)
+		if err != nil {
+			return "", err
+		}
+		c.save(token)
+		c.token = token
+	}
+	return c.token.AccessToken, nil
+}
+
+// save writes a newly issued token to the token file, if there is one
+func (c *oauthClient) save(token *oauthToken) {
+	if c.tokenFile == "" {
+		return
+	}
+	if err := saveOAuthToken(c.tokenFile, token); err != nil {
+		c.logger.Warn("could not save the access token", zap.String("tokenFile", c.tokenFile), zap.Error(err))
+	}
+}
+
+// refresh exchanges the current refresh token for a new access token
+func (c *oauthClient) refresh(ctx context.Context) (*oauthToken, error) {
+	if c.token.RefreshToken == "" {
+		return nil, errors.New("no refresh token")
+	}
+	token, err := c.exchange(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {c.token.RefreshToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// X rotates refresh tokens, but keep the old one if it didn't send a new one
+	if token.RefreshToken == "" {
+		token.RefreshToken = c.token.RefreshToken
+	}
+	return token, nil
+}
+
+// authorize asks the account owner to authorize the app by visiting the authorization URL, waits
+// for X to redirect them to the local callback server and exchanges the code it receives for a token
+func (c *oauthClient) authorize(ctx context.Context) (*oauthToken, error) {
+	verifier, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	state, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	listener, err := net.Listen("tcp", c.callbackAddr)
+	if err != nil {
+		return nil, fmt.Errorf("could not start the oauth callback server: %w", err)
+	}
+	redirectURI := "http://" + c.callbackAddr + "/callback"
+
+	// Only the first callback is waited on. Later ones, such as the browser reloading the page, must
+	// not block the server.
+	codes := make(chan string, 1)
+	errs := make(chan error, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/callback" {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		switch {
+		case q.Get("state") != state:
+			http.Error(w, "unexpected state", http.StatusBadRequest)
+			return
+		case q.Get("error") != "":
+			fmt.Fprintln(w, "tweetdeleter was not authorized. you can close this window.")
+			select {
+			case errs <- fmt.Errorf("authorization was denied: %s", q.Get("error")):
+			default:
+			}
+		default:
+			fmt.Fprintln(w, "tweetdeleter is authorized. you can close this window.")
+			select {
+			case codes <- q.Get("code"):
+			default:
+			}
+		}
+	})}
+	go func() { _ = server.Serve(listener) }()
+	defer server.Close()
+
+	authURL := c.authorizeURL + "?" + url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.clientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {strings.Join(oauthScopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}.Encode()
+	c.logger.Info("open this url in a browser logged into the account to authorize tweetdeleter", zap.String("url", authURL))
+	if c.openURL != nil {
+		if err := c.openURL(authURL); err != nil {
+			return nil, fmt.Errorf("could not open the authorization url: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, authorizeTimeout)
+	defer cancel()
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("the app was not authorized in time: %w", ctx.Err())
+	case err := <-errs:
+		return nil, err
+	case code := <-codes:
+		return c.exchange(ctx, url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {code},
+			"redirect_uri":  {redirectURI},
+			"code_verifier": {verifier},
+		})
+	}
+}
+
+// exchange requests a token from the token endpoint with form
+func (c *oauthClient) exchange(ctx context.Context, form url.Values) (*oauthToken, error) {
+	form.Set("client_id", c.clientID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// Confidential clients authenticate with their secret, public clients only send their id
+	if c.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(c.clientID), url.QueryEscape(c.clientSecret))
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token request failed: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var body struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("could not parse token response: %w", err)
+	}
+	if body.AccessToken == "" {
+		return nil, errors.New("token response did not include an access token")
+	}
+	token := &oauthToken{AccessToken: body.AccessToken, RefreshToken: body.RefreshToken}
+	if body.ExpiresIn > 0 {
+		token.ExpiresAt = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// loadOAuthToken reads the token saved at path, returning nil if there isn't one
+func loadOAuthToken(path string) (*oauthToken, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read token file: %w", err)
+	}
+	var token oauthToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("could not parse token file %s: %w", path, err)
+	}
+	return &token, nil
+}
+
+// saveOAuthToken writes token to path. Only the current user can read it since it grants access
+// to the account.
+func saveOAuthToken(path string, token *oauthToken) error {
+	data, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// randomString returns n random bytes encoded as unpadded base64url, which is valid as a PKCE code
+// verifier and as state
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
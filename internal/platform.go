@@ -0,0 +1,197 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxSessionRestarts is how many times a run will log in again after losing its session
+const maxSessionRestarts = 3
+
+// ItemKind is what an item is
+type ItemKind string
+
+// Item kinds
+const (
+	// ItemPost is something the account posted, such as a tweet, a Bluesky post or a Mastodon status
+	ItemPost ItemKind = "post"
+	// ItemRepost is the account's repost of something else, such as a retweet or a Mastodon boost
+	ItemRepost ItemKind = "repost"
+	// ItemLike is the account's like of something else, such as a Mastodon favourite
+	ItemLike ItemKind = "like"
+)
+
+// Item is something on a platform that the account made and that can be deleted
+type Item struct {
+	// ID identifies the item to the platform that enumerated it
+	ID   string
+	Kind ItemKind
+	// Target is the id of what a repost or like refers to, if the platform needs it to undo them
+	Target string
+	// CreatedAt is when the item was created, or the zero time if the platform doesn't say
+	CreatedAt time.Time
+}
+
+// Window is a span of the time range being deleted. Since is inclusive and Until is exclusive.
+type Window struct {
+	Since time.Time
+	Until time.Time
+}
+
+// contains reports whether t is in w
+func (w Window) contains(t time.Time) bool {
+	return !t.Before(w.Since) && t.Before(w.Until)
+}
+
+// Platform is a network that items are deleted from. The retention policy, which walks the time
+// range in windows, counts what was deleted and retries throttled calls, is shared by every platform,
+// while a Platform only finds and deletes items.
+//
+// Methods may return errors wrapping ErrRateLimited when the platform is throttling the account, in
+// which case the window is enumerated again once the limit resets.
+type Platform interface {
+	// Login starts a session on the platform. It is called again to start a new session if Enumerate
+	// or Delete report that the session was lost.
+	Login(ctx context.Context) error
+	// Enumerate returns the items in window that haven't been deleted yet. It may return only some
+	// of them, since it is called again once they've been deleted until it returns nothing new.
+	Enumerate(ctx context.Context, window Window) ([]Item, error)
+	// Delete deletes item, which was returned by Enumerate. Deleting an item that is already gone
+	// succeeds.
+	Delete(ctx context.Context, item Item) error
+}
+
+// progress records how far a run has gotten so that it can resume where it left off after
+// logging in again
+type progress struct {
+	since   time.Time // start of the first window that hasn't been fully deleted
+	deleted int       // number of items deleted across all sessions
+	lastID  string    // id of the last item confirmed deleted
+}
+
+// partialCompletion wraps err with ErrPartialCompletion if anything was deleted before it occurred
+func (p progress) partialCompletion(err error) error {
+	if p.deleted == 0 {
+		return err
+	}
+	return fmt.Errorf("%w after deleting %d items: %w", ErrPartialCompletion, p.deleted, err)
+}
+
+// purge deletes the items a platform enumerates in the time range, one window at a time
+type purge struct {
+	platform  Platform
+	logger    *zap.Logger
+	rateLimit *rateLimitMonitor
+	endDate   time.Time
+	// window is the length of the windows the time range is split into. The whole time range is
+	// enumerated at once if window is zero.
+	window time.Duration
+	// noun is what the platform calls its items in logs, such as "tweets"
+	noun     string
+	progress progress
+}
+
+// run deletes the items in the time range. If the platform loses its session, it logs in again and
+// resumes from the last window that wasn't fully deleted.
+func (p *purge) run(ctx context.Context) error {
+	for restarts := 0; ; restarts++ {
+		err := p.runSession(ctx)
+		if err == nil {
+			p.logger.Info("finished deleting "+p.noun, zap.Int(p.noun+"Deleted", p.progress.deleted))
+			return nil
+		}
+		if !errors.Is(err, errSessionLost) {
+			return p.progress.partialCompletion(err)
+		}
+		if restarts == maxSessionRestarts {
+			return p.progress.partialCompletion(fmt.Errorf("giving up after logging in again %d times: %w", restarts, err))
+		}
+
+		p.logger.Warn("lost session. logging in again and resuming",
+			zap.Error(err),
+			zap.Time("resumeFrom", p.progress.since),
+			zap.String("lastDeleted", p.progress.lastID),
+			zap.Int(p.noun+"Deleted", p.progress.deleted))
+	}
+}
+
+// runSession logs in and deletes the items in each window until all windows are complete or an
+// error occurs
+func (p *purge) runSession(ctx context.Context) error {
+	if err := p.rateLimit.retry(ctx, p.logger, func() error { return p.platform.Login(ctx) }); err != nil {
+		return err
+	}
+
+	for attempt := 0; p.progress.since.Before(p.endDate); {
+		window := Window{Since: p.progress.since, Until: p.endDate}
+		if p.window > 0 && window.Since.Add(p.window).Before(p.endDate) {
+			window.Until = window.Since.Add(p.window)
+		}
+
+		deleted := p.progress.deleted
+		err := p.deleteWindow(ctx, window)
+		if p.progress.deleted > deleted {
+			// Retries are counted per stall, since a whole time range enumerated as one window can be
+			// throttled many times while making steady progress
+			attempt = 0
+		}
+		if errors.Is(err, ErrRateLimited) && attempt < maxRateLimitRetries {
+			// Retry the same window once the platform is willing to talk to us again
+			if err := p.rateLimit.wait(ctx, p.logger, attempt); err != nil {
+				return err
+			}
+			attempt++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		p.progress.since, attempt = window.Until, 0
+	}
+	return nil
+}
+
+// deleteWindow deletes the items in window, enumerating them again after each round of deletions
+// until nothing new is left
+func (p *purge) deleteWindow(ctx context.Context, window Window) error {
+	// A like and a post can share an id when the account liked its own post
+	type key struct {
+		kind ItemKind
+		id   string
+	}
+	deleted := make(map[key]bool)
+	for {
+		items, err := p.platform.Enumerate(ctx, window)
+		if err != nil {
+			return err
+		}
+		pending := items[:0]
+		for _, item := range items {
+			if !deleted[key{item.Kind, item.ID}] {
+				pending = append(pending, item)
+			}
+		}
+		if len(pending) == 0 {
+			p.logger.Info(fmt.Sprintf("no more %s to delete from time range", p.noun),
+				zap.Time("startDate", window.Since), zap.Time("endDate", window.Until), zap.Int(p.noun+"Deleted", len(deleted)))
+			return nil
+		}
+
+		for _, item := range pending {
+			if err := p.platform.Delete(ctx, item); err != nil {
+				return err
+			}
+			deleted[key{item.Kind, item.ID}] = true
+			p.progress.deleted++
+			p.progress.lastID = item.ID
+			if p.progress.deleted%10 == 0 {
+				p.logger.Info(fmt.Sprintf("%d %s deleted", p.progress.deleted, p.noun))
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"tweetdeleter/internal/fakemastodon"
+)
+
+// shortenRateLimitPauses resumes as soon as a throttled server says its limit resets, for the rest of the test
+func shortenRateLimitPauses(t *testing.T) {
+	padding := rateLimitResetPadding
+	rateLimitResetPadding = 0
+	t.Cleanup(func() { rateLimitResetPadding = padding })
+}
+
+// Platforms that enumerate the whole time range as one window are throttled many times on a large
+// account. Each throttle is a new stall, so they mustn't add up to maxRateLimitRetries.
+func TestPurgeRetriesEveryStallInOneWindow(t *testing.T) {
+	shortenRateLimitPauses(t)
+	since := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	// Mastodon's default limit on deleting statuses is 30 every 30 minutes
+	instance := fakemastodon.NewInstance(fakemastodon.Options{
+		Username:    "fake",
+		AccessToken: "fake",
+		Statuses:    fakemastodon.GenerateStatuses(200, since, until),
+		RateLimit:   30,
+	})
+	server := fakemastodon.NewServer(instance)
+	defer server.Close()
+
+	md, err := NewMastodonDeleter(MastodonOptions{
+		Server:      server.URL,
+		AccessToken: "fake",
+		StartDate:   since,
+		EndDate:     until,
+		Logger:      zap.NewNop(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := md.Run(); err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+	if deleted := len(instance.Deleted()); deleted != 200 {
+		t.Errorf("deleted %d statuses, want 200", deleted)
+	}
+	if left := len(instance.Statuses()); left != 0 {
+		t.Errorf("%d statuses left, want 0", left)
+	}
+}
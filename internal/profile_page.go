@@ -0,0 +1,22 @@
+package internal
+
+// profilePage is a user's profile, listing their tweets newest first after any pinned tweet
+type profilePage struct {
+	screen
+	site site
+}
+
+// profilePage returns the profile page of the site tweets are deleted from
+func (t *TweetDeleter) profilePage() profilePage {
+	return profilePage{screen: t.screen, site: t.site}
+}
+
+// open loads the profile of username
+func (pp profilePage) open(username string) step {
+	return navigate(pp.site.profileURL(username))
+}
+
+// firstTweet returns the first tweet on the profile, which is the pinned tweet if there is one
+func (pp profilePage) firstTweet() tweetArticle {
+	return tweetArticle{screen: pp.screen}
+}
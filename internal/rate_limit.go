@@ -0,0 +1,176 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxRateLimitRetries is how many times in a row a window will be retried after being rate limited
+	maxRateLimitRetries = 5
+	// minRateLimitBackoff is the first pause used when X doesn't tell us when the limit resets
+	minRateLimitBackoff = time.Minute
+	// maxRateLimitBackoff caps the exponential backoff used when X doesn't tell us when the limit resets
+	maxRateLimitBackoff = 15 * time.Minute
+)
+
+// rateLimitResetPadding is added to the reset time provided by X to avoid resuming a moment too early.
+// Tests shorten it.
+var rateLimitResetPadding = 5 * time.Second
+
+// rateLimitMonitor watches the browser's network traffic, and the calls made to APIs directly, for
+// throttled API calls. The clients for those APIs record throttled responses with throttledResponse
+// and return an error wrapping ErrRateLimited.
+type rateLimitMonitor struct {
+	mu      sync.Mutex
+	limited bool
+	url     string
+	resetAt time.Time
+}
+
+// listen starts watching responses received by page
+func (m *rateLimitMonitor) listen(page Page) {
+	page.Listen(func(ev interface{}) {
+		if resp, ok := ev.(*ResponseEvent); ok && resp.Status == http.StatusTooManyRequests {
+			m.throttled(resp.URL, resp.Headers)
+		}
+	})
+}
+
+// throttled records a throttled response to url, along with when X said the limit resets if its
+// headers say
+func (m *rateLimitMonitor) throttled(url string, headers map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.limited = true
+	m.url = url
+	if reset, ok := rateLimitReset(headers); ok {
+		m.resetAt = reset
+	}
+}
+
+// throttledResponse records a throttled response to a call made to an API directly
+func (m *rateLimitMonitor) throttledResponse(resp *http.Response) {
+	headers := make(map[string]string, len(resp.Header))
+	for k := range resp.Header {
+		headers[k] = resp.Header.Get(k)
+	}
+	m.throttled(resp.Request.URL.String(), headers)
+}
+
+// status reports whether a throttled response has been seen since the last reset
+// along with the URL that was throttled and when X said the limit resets, if it did.
+func (m *rateLimitMonitor) status() (limited bool, url string, resetAt time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.limited, m.url, m.resetAt
+}
+
+// reset forgets any previously seen throttled responses
+func (m *rateLimitMonitor) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.limited = false
+	m.url = ""
+	m.resetAt = time.Time{}
+}
+
+// backoff returns how long to pause before retrying. The reset time reported by X is used when
+// available, otherwise an exponential backoff based on the number of previous attempts is used.
+func (m *rateLimitMonitor) backoff(attempt int) time.Duration {
+	_, _, resetAt := m.status()
+	if wait := time.Until(resetAt); wait > 0 {
+		return wait + rateLimitResetPadding
+	}
+
+	wait := minRateLimitBackoff << attempt
+	if wait <= 0 || wait > maxRateLimitBackoff {
+		wait = maxRateLimitBackoff
+	}
+	return wait
+}
+
+// rateLimitReset parses the header saying when the rate limit resets. X's x-rate-limit-reset and
+// Bluesky's ratelimit-reset are unix timestamps in seconds, while Mastodon's x-ratelimit-reset is an
+// ISO 8601 timestamp.
+func rateLimitReset(headers map[string]string) (time.Time, bool) {
+	for k, v := range headers {
+		if !strings.EqualFold(k, "x-rate-limit-reset") && !strings.EqualFold(k, "ratelimit-reset") &&
+			!strings.EqualFold(k, "x-ratelimit-reset") {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.Unix(secs, 0), true
+		}
+		if reset, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return reset, true
+		}
+		return time.Time{}, false
+	}
+	return time.Time{}, false
+}
+
+// errorBannerScript implements ScriptErrorBanner. X displays its generic "Something went wrong.
+// Try reloading." error in place of the timeline when throttling.
+var errorBannerScript = Script{Name: ScriptErrorBanner, Source: withLocate(`(banner) => {
+	return locate(banner, "") !== null;
+}`)}
+
+// checkRateLimited inspects the browser after err occurred to determine if it was caused by
+// X throttling the account. If it was, the returned error wraps ErrRateLimited.
+func (t *TweetDeleter) checkRateLimited(ctx context.Context, page Page, err error) error {
+	if limited, url, _ := t.rateLimit.status(); limited {
+		t.logger.Warn("x responded with 429 too many requests", zap.String("url", url))
+		return fmt.Errorf("%w: %w", ErrRateLimited, err)
+	}
+
+	ctx, cancel := quickCheck(ctx)
+	defer cancel()
+
+	var shown bool
+	if page.Evaluate(ctx, errorBannerScript, &shown, t.selectors.Search.ErrorBanner) == nil && shown {
+		t.logger.Warn("x is displaying its \"something went wrong\" error")
+		return fmt.Errorf("%w: %w", ErrRateLimited, err)
+	}
+	return err
+}
+
+// wait pauses until it's safe to retry after being rate limited attempt times in a row
+func (m *rateLimitMonitor) wait(ctx context.Context, logger *zap.Logger, attempt int) error {
+	wait := m.backoff(attempt)
+	logger.Warn("rate limited. pausing before retrying",
+		zap.Int("attempt", attempt+1), zap.Duration("wait", wait), zap.Time("resumeAt", time.Now().Add(wait)))
+	m.reset()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retry calls fn until it succeeds, fails with an error other than ErrRateLimited or has been rate
+// limited maxRateLimitRetries times in a row
+func (m *rateLimitMonitor) retry(ctx context.Context, logger *zap.Logger, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if !errors.Is(err, ErrRateLimited) || attempt == maxRateLimitRetries {
+			return err
+		}
+		if err := m.wait(ctx, logger, attempt); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// xMaxChars is the most characters X lets an account without a subscription post
+const xMaxChars = 280
+
+// RestoreOptions configure reposting deleted tweets from an archive with Restore
+type RestoreOptions struct {
+	// Archive is an X data archive downloaded before the tweets were deleted. It can be the zip file or
+	// the directory it was extracted to.
+	Archive string
+	// AttributeDate ends each restored tweet with the date it was originally posted, cutting the text
+	// short if the tweet would otherwise be too long
+	AttributeDate bool
+	// Delay is how long to pause between posts, so that restoring many tweets doesn't run into X's
+	// limits on how often an account can post
+	Delay time.Duration
+	// FromTweet is the id of the first tweet to restore, for resuming a restore that stopped part way.
+	// The tweets in the time range posted before it are skipped.
+	FromTweet string
+	// DryRun logs the tweets that would be restored without launching chrome or posting anything
+	DryRun bool
+}
+
+// restore reposts archived tweets one at a time through the browser, oldest first so that they
+// end up in the same order on the account's timeline
+type restore struct {
+	t        *TweetDeleter
+	opts     RestoreOptions
+	archive  *tweetArchive
+	tweets   []archivedTweet
+	next     int // index of the next tweet to post
+	restored int
+	// unsure is set when posting the next tweet failed, since X may have posted it before failing
+	unsure bool
+}
+
+// Restore reposts the text and media of the tweets in an archive that were posted between the start
+// and end dates, as a way to recover from deleting the wrong time range. The restored tweets are new
+// tweets, so their likes, replies and reposts are not restored. Reposts and replies are skipped since
+// they can't be posted the way they originally were. If chrome crashes or X logs us out, chrome is
+// relaunched and restoring resumes from the first tweet that wasn't posted. A tweet that failed to
+// post is looked for on the account's profile before it is posted again, since X may have posted it
+// anyway.
+func (t *TweetDeleter) Restore(opts RestoreOptions) error {
+	archive, err := openTweetArchive(opts.Archive)
+	if err != nil {
+		return err
+	}
+	defer archive.close()
+
+	r := &restore{t: t, opts: opts, archive: archive}
+	skipped := 0
+	for _, tweet := range archive.between(t.startDate, t.endDate) {
+		if !copyable(tweet) {
+			skipped++
+			continue
+		}
+		r.tweets = append(r.tweets, tweet)
+	}
+	if opts.FromTweet != "" {
+		i := slices.IndexFunc(r.tweets, func(tweet archivedTweet) bool { return tweet.ID == opts.FromTweet })
+		if i < 0 {
+			return fmt.Errorf("tweet %s isn't one of the archived tweets to restore in the time range", opts.FromTweet)
+		}
+		r.tweets = r.tweets[i:]
+	}
+	t.logger.Info("read tweet archive", zap.String("archive", opts.Archive),
+		zap.Int("tweetsToRestore", len(r.tweets)), zap.Int("repliesAndRepostsSkipped", skipped))
+
+	if opts.DryRun {
+		r.dryRun()
+		return nil
+	}
+	if len(r.tweets) == 0 {
+		t.logger.Info("no tweets to restore in the time range")
+		return nil
+	}
+	defer t.closeSession()
+	return r.run(context.Background())
+}
+
+// dryRun logs each tweet that would be restored along with the text it would be posted with
+func (r *restore) dryRun() {
+	for _, tweet := range r.tweets {
+		r.t.logger.Info("would restore tweet",
+			zap.String("tweetID", tweet.ID),
+			zap.Time("createdAt", tweet.CreatedAt),
+			zap.String("text", r.text(tweet)),
+			zap.Int("media", len(tweet.Media)))
+	}
+	r.t.logger.Info("dry run finished. nothing was posted", zap.Int("tweets", len(r.tweets)))
+}
+
+// run posts the tweets. If the session is lost, it logs in again and resumes from the first tweet
+// that wasn't posted.
+func (r *restore) run(ctx context.Context) error {
+	for restarts := 0; ; restarts++ {
+		err := r.runSession(ctx)
+		if err == nil {
+			r.t.logger.Info("finished restoring tweets", zap.Int("tweetsRestored", r.restored))
+			return nil
+		}
+		if !errors.Is(err, errSessionLost) {
+			return r.partialCompletion(err)
+		}
+		if restarts == maxSessionRestarts {
+			return r.partialCompletion(fmt.Errorf("giving up after logging in again %d times: %w", restarts, err))
+		}
+
+		fields := []zap.Field{zap.Error(err), zap.Int("tweetsRestored", r.restored)}
+		if r.next < len(r.tweets) {
+			fields = append(fields, zap.String("nextTweet", r.tweets[r.next].ID))
+		}
+		r.t.logger.Warn("lost session. logging in again and resuming", fields...)
+	}
+}
+
+// runSession logs in and posts the remaining tweets, pausing between posts
+func (r *restore) runSession(ctx context.Context) error {
+	if err := r.t.rateLimit.retry(ctx, r.t.logger, func() error { return r.t.Login(ctx) }); err != nil {
+		return err
+	}
+
+	for ; r.next < len(r.tweets); r.next++ {
+		if r.restored > 0 {
+			if err := sleep(r.opts.Delay)(ctx, nil); err != nil {
+				return err
+			}
+		}
+
+		tweet := r.tweets[r.next]
+		if err := r.t.rateLimit.retry(ctx, r.t.logger, func() error { return r.postOnce(tweet) }); err != nil {
+			return err
+		}
+		r.restored++
+		r.t.logger.Info("restored tweet", zap.String("tweetID", tweet.ID), zap.Time("createdAt", tweet.CreatedAt))
+	}
+	return nil
+}
+
+// postOnce posts tweet, unless the last attempt to post it failed after X had already posted it
+func (r *restore) postOnce(tweet archivedTweet) error {
+	if r.unsure {
+		posted, err := r.posted(tweet)
+		if err != nil {
+			return err
+		}
+		if posted {
+			r.t.logger.Info("tweet was posted before posting it failed. not posting it again", zap.String("tweetID", tweet.ID))
+			r.unsure = false
+			return nil
+		}
+	}
+	err := r.post(tweet)
+	r.unsure = err != nil
+	return err
+}
+
+// posted reports whether the latest tweet on the account's profile, below any pinned tweet, is the
+// copy of tweet. Tweets without text can't be told apart on the profile, so they're never reported as
+// posted.
+func (r *restore) posted(tweet archivedTweet) (bool, error) {
+	text := r.text(tweet)
+	if text == "" {
+		return false, nil
+	}
+
+	profile := r.t.profilePage()
+	latest := profile.firstTweet()
+	var id, shown string
+	var pinned bool
+	s := r.t.session
+	err := runStep(s.ctx, s.page, profile.open(r.t.username), latest.mark(&id), latest.isPinned(&pinned))
+	if err == nil && pinned {
+		err = runStep(s.ctx, s.page, latest.remove(), latest.mark(&id))
+	}
+	if err == nil {
+		err = runStep(s.ctx, s.page, latest.text(&shown))
+	}
+	if errors.Is(err, ErrSelectorMissing) {
+		// The profile has no tweets, other than a pinned one, unless it never loaded. Posting the tweet
+		// again finds out which.
+		return false, nil
+	}
+	if err != nil {
+		return false, r.t.checkStepError(s, fmt.Errorf("could not check whether tweet %s was restored: %w", tweet.ID, err))
+	}
+	return strings.TrimSpace(shown) == strings.TrimSpace(text), nil
+}
+
+// post posts a copy of tweet, with its media attached, through the composer
+func (r *restore) post(tweet archivedTweet) error {
+	paths, cleanup, err := r.writeMedia(tweet)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	compose := r.t.composePage()
+	ss := []step{compose.open()}
+	if text := r.text(tweet); text != "" {
+		ss = append(ss, compose.write(text))
+	}
+	if len(paths) > 0 {
+		ss = append(ss, compose.attach(paths))
+	}
+	ss = append(ss, compose.post(), compose.waitSent())
+
+	s := r.t.session
+	if err := runStep(s.ctx, s.page, ss...); err != nil {
+		return r.t.checkStepError(s, fmt.Errorf("could not restore tweet %s: %w", tweet.ID, err))
+	}
+	return nil
+}
+
+// text returns the text tweet is restored with. Tweets longer than X allows, such as ones posted
+// with a subscription, are cut short.
+func (r *restore) text(tweet archivedTweet) string {
+	if !r.opts.AttributeDate {
+		return truncate(tweet.Text, xMaxChars)
+	}
+	return withNote(tweet.Text, "(Originally posted on "+tweet.CreatedAt.Format("2 January 2006")+")", xMaxChars)
+}
+
+// writeMedia writes the media attached to tweet to a temporary directory so that the browser can
+// upload them. cleanup removes the directory.
+func (r *restore) writeMedia(tweet archivedTweet) (paths []string, cleanup func(), err error) {
+	cleanup = func() {}
+	if len(tweet.Media) == 0 {
+		return nil, cleanup, nil
+	}
+	media, err := r.archive.media(tweet)
+	if err != nil {
+		return nil, cleanup, err
+	}
+
+	dir, err := os.MkdirTemp("", "tweetdeleter-restore-")
+	if err != nil {
+		return nil, cleanup, fmt.Errorf("could not write media of tweet %s: %w", tweet.ID, err)
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+	for _, m := range media {
+		path := filepath.Join(dir, m.Name)
+		if err := os.WriteFile(path, m.Data, 0o600); err != nil {
+			cleanup()
+			return nil, func() {}, fmt.Errorf("could not write media of tweet %s: %w", tweet.ID, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, cleanup, nil
+}
+
+// partialCompletion wraps err with ErrPartialCompletion if any tweets were restored before it
+// occurred, logging the tweet restoring stopped at so that it can be resumed from it with FromTweet
+func (r *restore) partialCompletion(err error) error {
+	if r.restored == 0 {
+		return err
+	}
+	if r.next < len(r.tweets) {
+		next := r.tweets[r.next]
+		r.t.logger.Warn("stopped restoring tweets before this one",
+			zap.String("tweetID", next.ID), zap.Time("createdAt", next.CreatedAt))
+	}
+	return fmt.Errorf("%w after restoring %d tweets: %w", ErrPartialCompletion, r.restored, err)
+}
@@ -0,0 +1,99 @@
+package internal_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"tweetdeleter/internal"
+	"tweetdeleter/internal/fakex"
+)
+
+// noBrowser is a Driver that fails the test if a browser is launched
+type noBrowser struct {
+	t *testing.T
+}
+
+func (d noBrowser) NewPage() (internal.Page, error) {
+	d.t.Error("launched a browser")
+	return nil, errors.New("no browser")
+}
+
+// restore restores the tweets archived in archive from runSince to runUntil with driver, returning
+// the logs it wrote and Restore's error
+func restore(t *testing.T, driver internal.Driver, archive string) (*observer.ObservedLogs, error) {
+	t.Helper()
+	core, logs := observer.New(zap.InfoLevel)
+	td, err := internal.NewTweetDeleter(internal.TweetDeleterOptions{
+		Username:  "fake",
+		Password:  "secret",
+		StartDate: runSince,
+		EndDate:   runUntil,
+		Logger:    zap.New(core),
+		Driver:    driver,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = td.Restore(internal.RestoreOptions{Archive: archive})
+	return logs, err
+}
+
+// A tweet that X posted before logging the account out isn't posted again once restoring resumes,
+// even with a pinned tweet above it on the profile
+func TestRestoreDoesNotRepostTweetPostedBeforeLosingSession(t *testing.T) {
+	tweets := fakex.GenerateTweets(3, runSince, runUntil)
+	archive := t.TempDir()
+	if err := fakex.WriteArchive(archive, tweets, nil); err != nil {
+		t.Fatal(err)
+	}
+	pinned := fakex.Tweet{ID: "1", Text: "read this first", CreatedAt: runSince.Add(-time.Hour)}
+	site := fakex.NewSite(fakex.Options{
+		Username:         "fake",
+		Password:         "secret",
+		Tweets:           []fakex.Tweet{pinned},
+		Pinned:           pinned.ID,
+		LogOutAfterPosts: 2,
+	})
+
+	logs, err := restore(t, fakex.NewDriver(site), archive)
+	if err != nil {
+		t.Fatalf("Restore() = %v, want nil", err)
+	}
+
+	posted := make(map[string]int)
+	for _, tweet := range site.Tweets() {
+		posted[tweet.Text]++
+	}
+	for _, tweet := range tweets {
+		if posted[tweet.Text] != 1 {
+			t.Errorf("%q was posted %d times, want once", tweet.Text, posted[tweet.Text])
+		}
+	}
+	if n := logs.FilterMessage("lost session. logging in again and resuming").Len(); n != 1 {
+		t.Errorf("lost the session %d times, want once", n)
+	}
+	finished := logs.FilterMessage("finished restoring tweets").All()
+	if len(finished) != 1 || finished[0].ContextMap()["tweetsRestored"] != int64(len(tweets)) {
+		t.Errorf("finished restoring tweets with %v, want %d restored", finished, len(tweets))
+	}
+}
+
+// Restoring a time range with no archived tweets finishes without launching a browser
+func TestRestoreWithNothingToRestore(t *testing.T) {
+	archive := t.TempDir()
+	if err := fakex.WriteArchive(archive, fakex.GenerateTweets(3, runSince.AddDate(-1, 0, 0), runSince), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	logs, err := restore(t, noBrowser{t}, archive)
+	if err != nil {
+		t.Fatalf("Restore() = %v, want nil", err)
+	}
+	if n := logs.FilterMessage("no tweets to restore in the time range").Len(); n != 1 {
+		t.Errorf("logged that there was nothing to restore %d times, want once", n)
+	}
+}
@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// tweetIDAttr marks the tweets collected from the results with their id
+const tweetIDAttr = "data-tweetdeleter-id"
+
+// searchResult describes what a search results page finished rendering
+type searchResult string
+
+const (
+	searchResultTweets searchResult = "tweets"
+	searchResultEmpty  searchResult = "empty"
+	searchResultError  searchResult = "error"
+)
+
+// searchResultsScript implements ScriptSearchResults
+var searchResultsScript = Script{Name: ScriptSearchResults, Source: withLocate(`(tab, tweet, empty, error) => {
+	const banner = locate(error, "");
+	if (banner) {
+		return { state: "error", index: banner.index };
+	}
+	if (!locate(tab, "")) {
+		return false;
+	}
+	const tweets = locate(tweet, "");
+	if (tweets) {
+		return { state: "tweets", index: tweets.index };
+	}
+	const emptyState = locate(empty, "");
+	if (emptyState) {
+		return { state: "empty", index: emptyState.index };
+	}
+	return false;
+}`)}
+
+// collectTweetsScript implements ScriptCollectTweets. Each tweet is marked with an empty attribute
+// while its permalink is looked up so that the lookup is scoped to it.
+var collectTweetsScript = Script{Name: ScriptCollectTweets, Source: withLocate(`(tweet, permalink, attr, max) => {
+	const tweets = locate(tweet, "");
+	if (!tweets) {
+		return false;
+	}
+	document.querySelectorAll("[" + attr + "]").forEach((el) => el.removeAttribute(attr));
+
+	const ids = [];
+	for (const article of tweets.elements.slice(0, max)) {
+		article.setAttribute(attr, "");
+		const links = locate(permalink, "[" + attr + "=\"\"]")?.elements ?? [];
+		const link = links.find((a) => a.querySelector("time") !== null) ?? links[0];
+		const id = link?.getAttribute("href")?.match(/\/status\/(\d+)/)?.[1];
+		if (id) {
+			article.setAttribute(attr, id);
+			ids.push(id);
+		} else {
+			article.removeAttribute(attr);
+		}
+	}
+	return { ids, index: tweets.index };
+}`)}
+
+// searchPage is the explore page's search box and the results it leads to
+type searchPage struct {
+	screen
+	site site
+}
+
+// searchPage returns the search page of the site tweets are deleted from
+func (t *TweetDeleter) searchPage() searchPage {
+	return searchPage{screen: t.screen, site: t.site}
+}
+
+// searchTweets opens the "Latest" results of searching for the tweets username posted between
+// since and until, newest first. waitForResults confirms the tab is active.
+func (s searchPage) searchTweets(username string, since, until time.Time) step {
+	query := fmt.Sprintf("from:%s since:%s until:%s", username, since.Format(time.DateOnly), until.Format(time.DateOnly))
+	return navigate(s.site.searchURL(query))
+}
+
+// waitForResults blocks until the "Latest" tab is active and has rendered either tweets,
+// the empty state or X's "Something went wrong" error banner. The predicate is re-evaluated on every
+// DOM mutation instead of sleeping for a fixed amount of time.
+func (s searchPage) waitForResults(res *searchResult) step {
+	return func(ctx context.Context, p Page) error {
+		var state struct {
+			State string `json:"state"`
+			Index int    `json:"index"`
+		}
+		err := p.Poll(ctx, searchResultsScript, &state, PollOptions{Mutation: true, Timeout: pageReadyTimeout},
+			s.selectors.Search.ActiveLatestTab, s.selectors.Tweet.Article, s.selectors.Search.EmptyState, s.selectors.Search.ErrorBanner)
+		if err != nil {
+			return fmt.Errorf("search results never rendered: %w", err)
+		}
+
+		switch *res = searchResult(state.State); *res {
+		case searchResultTweets:
+			s.logFallback("tweet.article", s.selectors.Tweet.Article, state.Index)
+		case searchResultEmpty:
+			s.logFallback("search.emptyState", s.selectors.Search.EmptyState, state.Index)
+		case searchResultError:
+			s.logFallback("search.errorBanner", s.selectors.Search.ErrorBanner, state.Index)
+		}
+		return nil
+	}
+}
+
+// collectTweets marks up to max of the tweets in the results with their id in attr and stores their ids in ids
+func (s searchPage) collectTweets(max int, attr string, ids *[]string) step {
+	return func(ctx context.Context, p Page) error {
+		var collected struct {
+			IDs   []string `json:"ids"`
+			Index int      `json:"index"`
+		}
+		err := p.Poll(ctx, collectTweetsScript, &collected, PollOptions{Mutation: true, Timeout: pageReadyTimeout},
+			s.selectors.Tweet.Article, s.selectors.Tweet.Permalink, attr, max)
+		if err != nil {
+			return fmt.Errorf("could not find any tweets: %w", err)
+		}
+		if len(collected.IDs) == 0 {
+			return errors.New("could not find the ids of the tweets in the results")
+		}
+
+		s.logFallback("tweet.article", s.selectors.Tweet.Article, collected.Index)
+		*ids = collected.IDs
+		return nil
+	}
+}
+
+// firstTweet returns the first tweet in the results
+func (s searchPage) firstTweet() tweetArticle {
+	return tweetArticle{screen: s.screen}
+}
+
+// collectedTweet returns the tweet with id that collectTweets marked in the results
+func (s searchPage) collectedTweet(id string) tweetArticle {
+	return tweetArticle{screen: s.screen, id: id}
+}
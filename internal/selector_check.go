@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// expectAttr marks the elements of a selector fixture that an element's locators are expected to
+// match. Its value is a space separated list of element names, such as "tweet.article".
+const expectAttr = "data-tweetdeleter-expect"
+
+// loadFixtureScript implements ScriptLoadFixture. The fixture is parsed with DOMParser so that
+// none of the scripts saved with the page run.
+var loadFixtureScript = Script{Name: ScriptLoadFixture, Source: `(html, attr) => {
+	const doc = new DOMParser().parseFromString(html, "text/html");
+	document.replaceChild(document.adoptNode(doc.documentElement), document.documentElement);
+
+	const names = new Set();
+	for (const el of document.querySelectorAll("[" + attr + "]")) {
+		el.getAttribute(attr).split(/\s+/).filter((name) => name !== "").forEach((name) => names.add(name));
+	}
+	return Array.from(names);
+}`}
+
+// checkFixtureScript implements ScriptCheckFixture
+var checkFixtureScript = Script{Name: ScriptCheckFixture, Source: withLocate(`(name, locators, attr) => {
+	const expected = Array.from(document.querySelectorAll("[" + attr + "]"))
+		.filter((el) => el.getAttribute(attr).split(/\s+/).includes(name));
+	const found = locate(locators, "");
+	const matched = found ? found.elements : [];
+	return {
+		index: found ? found.index : -1,
+		matched: matched.length,
+		missing: expected.filter((el) => !matched.includes(el)).length,
+		unexpected: matched.filter((el) => !expected.includes(el)).length,
+	};
+}`)}
+
+// fixtureMatch is how an element's locators resolved in a selector fixture
+type fixtureMatch struct {
+	Index      int `json:"index"`
+	Matched    int `json:"matched"`
+	Missing    int `json:"missing"`
+	Unexpected int `json:"unexpected"`
+}
+
+// CheckSelectors loads every .html file in fixtureDir into the browser and checks that the locators
+// of each element the fixture expects, by marking elements with a data-tweetdeleter-expect attribute,
+// match exactly the marked elements. Scripts in the fixtures are not run. Elements that no fixture
+// expects are reported as skipped.
+func (t *TweetDeleter) CheckSelectors(fixtureDir string) ([]CheckResult, error) {
+	fixtures, err := filepath.Glob(filepath.Join(fixtureDir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+	if len(fixtures) == 0 {
+		return nil, fmt.Errorf("no .html fixtures in %s", fixtureDir)
+	}
+	sort.Strings(fixtures)
+
+	page, err := t.driver.NewPage()
+	if err != nil {
+		return nil, err
+	}
+	defer page.Close()
+
+	var results []CheckResult
+	checked := make(map[string]bool)
+	for _, fixture := range fixtures {
+		res, err := t.checkFixture(page, fixture, checked)
+		if err != nil {
+			return nil, fmt.Errorf("could not check fixture %s: %w", fixture, err)
+		}
+		results = append(results, res...)
+	}
+	for _, n := range t.selectors.named() {
+		if !checked[n.name] {
+			results = append(results, CheckResult{Name: "selector " + n.name, Status: CheckSkipped, Detail: "no fixture expects it"})
+		}
+	}
+	return results, nil
+}
+
+// checkFixture checks the elements expected by a single fixture, recording each one it checks in checked
+func (t *TweetDeleter) checkFixture(page Page, fixture string, checked map[string]bool) ([]CheckResult, error) {
+	html, err := os.ReadFile(fixture)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), stepTimeout)
+	defer cancel()
+	if err := page.Navigate(ctx, "about:blank"); err != nil {
+		return nil, err
+	}
+	var names []string
+	if err := page.Evaluate(ctx, loadFixtureScript, &names, string(html), expectAttr); err != nil {
+		return nil, err
+	}
+	expected := make(map[string]bool)
+	for _, name := range names {
+		expected[name] = true
+	}
+
+	var results []CheckResult
+	base := filepath.Base(fixture)
+	for _, n := range t.selectors.named() {
+		if !expected[n.name] {
+			continue
+		}
+		delete(expected, n.name)
+		checked[n.name] = true
+
+		var m fixtureMatch
+		if err := page.Evaluate(ctx, checkFixtureScript, &m, n.name, n.locators, expectAttr); err != nil {
+			return nil, err
+		}
+		results = append(results, fixtureResult(base, n, m))
+	}
+
+	unknown := make([]string, 0, len(expected))
+	for name := range expected {
+		unknown = append(unknown, name)
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		results = append(results, CheckResult{
+			Name:   fmt.Sprintf("selector %s in %s", name, base),
+			Status: CheckFailed,
+			Detail: "expected by the fixture but not in the selector profile",
+		})
+	}
+	return results, nil
+}
+
+// fixtureResult describes how the element n resolved in a fixture
+func fixtureResult(fixture string, n namedLocators, m fixtureMatch) CheckResult {
+	r := CheckResult{Name: fmt.Sprintf("selector %s in %s", n.name, fixture), Status: CheckPassed}
+	if m.Index < 0 || m.Index >= len(n.locators) {
+		r.Status = CheckFailed
+		r.Detail = fmt.Sprintf("no locator matched the %d expected elements", m.Missing)
+		return r
+	}
+
+	r.Detail = fmt.Sprintf("found %d by %s", m.Matched, n.locators[m.Index])
+	if m.Index > 0 {
+		r.Detail += " (fallback)"
+	}
+	if m.Missing > 0 || m.Unexpected > 0 {
+		r.Status = CheckFailed
+		r.Detail += fmt.Sprintf(": %d expected elements missing, %d unexpected elements matched", m.Missing, m.Unexpected)
+	}
+	return r
+}
@@ -0,0 +1,34 @@
+package internal_test
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+
+	"tweetdeleter/internal"
+)
+
+// The default selector profile must resolve every element to exactly the elements marked in the
+// snapshots of X's pages, the same check selector-check runs
+func TestDefaultSelectorsMatchFixtures(t *testing.T) {
+	if _, err := internal.FindChrome(); err != nil {
+		t.Skip(err)
+	}
+	td, err := internal.NewTweetDeleter(internal.TweetDeleterOptions{Logger: zap.NewNop(), Headless: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := td.CheckSelectors("../fixtures/selectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		switch r.Status {
+		case internal.CheckFailed:
+			t.Errorf("%s: %s", r.Name, r.Detail)
+		case internal.CheckSkipped:
+			t.Errorf("%s: %s. add a fixture marking it", r.Name, r.Detail)
+		}
+	}
+}
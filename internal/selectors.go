@@ -0,0 +1,162 @@
+package internal
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// selectorProfileVersion is the version of the selector profile format understood by this build
+const selectorProfileVersion = 3
+
+//go:embed selectors.json
+var defaultSelectorProfile []byte
+
+// SelectorProfile holds the locators used to find elements on X's pages. A profile ships
+// embedded in the binary and can be overridden from a JSON file so that X changing its UI
+// doesn't require a new build. Each element has an ordered list of locators so that a single
+// renamed attribute falls back to another strategy instead of breaking the whole tool.
+type SelectorProfile struct {
+	Version int              `json:"version"`
+	Login   LoginSelectors   `json:"login"`
+	Session SessionSelectors `json:"session"`
+	Search  SearchSelectors  `json:"search"`
+	Tweet   TweetSelectors   `json:"tweet"`
+	Compose ComposeSelectors `json:"compose"`
+}
+
+// LoginSelectors are the elements of X's login flow
+type LoginSelectors struct {
+	UsernameInput  Locators `json:"usernameInput"`
+	NextButton     Locators `json:"nextButton"`
+	PasswordInput  Locators `json:"passwordInput"`
+	SubmitButton   Locators `json:"submitButton"`
+	ChallengeInput Locators `json:"challengeInput"`
+	ErrorMessage   Locators `json:"errorMessage"`
+}
+
+// SessionSelectors are elements that tell us whether the browser is logged in
+type SessionSelectors struct {
+	LoggedIn  Locators `json:"loggedIn"`
+	LoggedOut Locators `json:"loggedOut"`
+}
+
+// SearchSelectors are the elements of X's search results. Searches are opened by URL, so neither the
+// search box nor the tabs are ever clicked.
+type SearchSelectors struct {
+	ActiveLatestTab Locators `json:"activeLatestTab"`
+	EmptyState      Locators `json:"emptyState"`
+	ErrorBanner     Locators `json:"errorBanner"`
+}
+
+// TweetSelectors are the elements used to delete a tweet. Permalink, PinnedLabel, Text and MoreButton
+// are found inside the tweet's article.
+type TweetSelectors struct {
+	Article        Locators `json:"article"`
+	Permalink      Locators `json:"permalink"`
+	PinnedLabel    Locators `json:"pinnedLabel"`
+	Text           Locators `json:"text"`
+	MoreButton     Locators `json:"moreButton"`
+	Menu           Locators `json:"menu"`
+	MenuItem       Locators `json:"menuItem"`
+	DeleteMenuItem Locators `json:"deleteMenuItem"`
+	ConfirmButton  Locators `json:"confirmButton"`
+}
+
+// ComposeSelectors are the elements of the composer used to post tweets when restoring them. MediaInput
+// is the hidden file input behind the composer's media button.
+type ComposeSelectors struct {
+	TextArea   Locators `json:"textArea"`
+	MediaInput Locators `json:"mediaInput"`
+	PostButton Locators `json:"postButton"`
+	Error      Locators `json:"error"`
+}
+
+// namedLocators are an element's locators along with the name used for it in a selector profile
+type namedLocators struct {
+	name     string
+	locators Locators
+}
+
+// DefaultSelectorProfile returns the selector profile embedded in the binary
+func DefaultSelectorProfile() *SelectorProfile {
+	var p SelectorProfile
+	if err := json.Unmarshal(defaultSelectorProfile, &p); err != nil {
+		panic(fmt.Sprintf("embedded selector profile is invalid: %v", err))
+	}
+	return &p
+}
+
+// LoadSelectorProfile reads a selector profile from path. Selectors missing from the
+// file fall back to the ones in the embedded profile, but the file must declare its own version.
+func LoadSelectorProfile(path string) (*SelectorProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read selector profile: %w", err)
+	}
+
+	// Checked before merging, since a missing version would otherwise be the embedded profile's
+	var declared struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &declared); err != nil {
+		return nil, fmt.Errorf("could not parse selector profile %s: %w", path, err)
+	}
+	if declared.Version == nil {
+		return nil, fmt.Errorf("invalid selector profile %s: missing version, expected at most %d", path, selectorProfileVersion)
+	}
+
+	p := DefaultSelectorProfile()
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("could not parse selector profile %s: %w", path, err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("invalid selector profile %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// validate ensures the profile is a version this build understands and every element has valid locators.
+// Version 1 profiles, where every element is a single CSS selector, are still accepted.
+func (p *SelectorProfile) validate() error {
+	if p.Version < 1 || p.Version > selectorProfileVersion {
+		return fmt.Errorf("unsupported version %d, expected at most %d", p.Version, selectorProfileVersion)
+	}
+	for _, n := range p.named() {
+		if err := n.locators.validate(); err != nil {
+			return fmt.Errorf("%s: %w", n.name, err)
+		}
+	}
+	return nil
+}
+
+// named lists every element in the profile by its name in the profile's JSON
+func (p *SelectorProfile) named() []namedLocators {
+	return []namedLocators{
+		{"login.usernameInput", p.Login.UsernameInput},
+		{"login.nextButton", p.Login.NextButton},
+		{"login.passwordInput", p.Login.PasswordInput},
+		{"login.submitButton", p.Login.SubmitButton},
+		{"login.challengeInput", p.Login.ChallengeInput},
+		{"login.errorMessage", p.Login.ErrorMessage},
+		{"session.loggedIn", p.Session.LoggedIn},
+		{"session.loggedOut", p.Session.LoggedOut},
+		{"search.activeLatestTab", p.Search.ActiveLatestTab},
+		{"search.emptyState", p.Search.EmptyState},
+		{"search.errorBanner", p.Search.ErrorBanner},
+		{"tweet.article", p.Tweet.Article},
+		{"tweet.permalink", p.Tweet.Permalink},
+		{"tweet.pinnedLabel", p.Tweet.PinnedLabel},
+		{"tweet.text", p.Tweet.Text},
+		{"tweet.moreButton", p.Tweet.MoreButton},
+		{"tweet.menu", p.Tweet.Menu},
+		{"tweet.menuItem", p.Tweet.MenuItem},
+		{"tweet.deleteMenuItem", p.Tweet.DeleteMenuItem},
+		{"tweet.confirmButton", p.Tweet.ConfirmButton},
+		{"compose.textArea", p.Compose.TextArea},
+		{"compose.mediaInput", p.Compose.MediaInput},
+		{"compose.postButton", p.Compose.PostButton},
+		{"compose.error", p.Compose.Error},
+	}
+}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// defaultBaseURL is the site tweets are deleted from when no base URL is configured
+const defaultBaseURL = "https://x.com"
+
+// xDomains are the domains X is served from. Each is an alias of the others.
+var xDomains = []string{"x.com", "twitter.com"}
+
+// site is the deployment of X that tweets are deleted from along with the other domains
+// that serve it. All URLs used by TweetDeleter are built from its site.
+type site struct {
+	// baseURL is the scheme and host of the site, such as https://x.com, a mirror or a local stand-in
+	baseURL string
+	// aliases are other base URLs serving the same site, such as https://twitter.com for x.com. The
+	// session cookies set by baseURL are copied to them after logging in so that links and redirects
+	// between them don't lose the session.
+	aliases []string
+}
+
+// newSite creates the site served from baseURL. If baseURL is one of X's domains the others are
+// added as aliases. https://x.com is used if baseURL is empty.
+func newSite(baseURL string) (site, error) {
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return site{}, fmt.Errorf("invalid base url %q: %w", baseURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return site{}, fmt.Errorf("invalid base url %q: must be an absolute http or https url", baseURL)
+	}
+	if strings.Trim(u.Path, "/") != "" || u.RawQuery != "" || u.Fragment != "" {
+		return site{}, fmt.Errorf("invalid base url %q: must not have a path, query or fragment", baseURL)
+	}
+
+	s := site{baseURL: u.Scheme + "://" + u.Host}
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+	for _, domain := range xDomains {
+		if host == domain {
+			for _, alias := range xDomains {
+				if alias != domain {
+					s.aliases = append(s.aliases, u.Scheme+"://"+alias)
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+// loginURL is the start of the login flow
+func (s site) loginURL() string {
+	return s.baseURL + "/i/flow/login"
+}
+
+// homeURL is the logged in home timeline
+func (s site) homeURL() string {
+	return s.baseURL + "/home"
+}
+
+// searchURL is the "Latest" tab of the results of searching for query. src=typed_query makes
+// it the same URL X navigates to when the query is typed into the search box.
+func (s site) searchURL(query string) string {
+	return s.baseURL + "/search?q=" + url.QueryEscape(query) + "&f=live&src=typed_query"
+}
+
+// composeURL is the composer X opens over the home timeline to post a tweet
+func (s site) composeURL() string {
+	return s.baseURL + "/compose/post"
+}
+
+// profileURL is the profile page of username, listing their tweets
+func (s site) profileURL(username string) string {
+	return s.baseURL + "/" + url.PathEscape(username)
+}
+
+// syncCookies copies the cookies the browser has for the site's base URL to each of its aliases,
+// so that the session survives being sent from one domain to another
+func (t *TweetDeleter) syncCookies() step {
+	return func(ctx context.Context, p Page) error {
+		if len(t.site.aliases) == 0 {
+			return nil
+		}
+
+		cookies, err := p.Cookies(ctx, t.site.baseURL)
+		if err != nil {
+			return fmt.Errorf("could not read cookies for %s: %w", t.site.baseURL, err)
+		}
+
+		for _, alias := range t.site.aliases {
+			aliasURL, err := url.Parse(alias)
+			if err != nil {
+				return err
+			}
+			for _, c := range cookies {
+				// Cookies scoped to the whole domain start with a dot and stay that way
+				domain := aliasURL.Hostname()
+				if strings.HasPrefix(c.Domain, ".") {
+					domain = "." + strings.TrimPrefix(domain, "www.")
+				}
+				c.Domain = domain
+				if err := p.SetCookie(ctx, c); err != nil {
+					return fmt.Errorf("could not copy cookie %s to %s: %w", c.Name, c.Domain, err)
+				}
+			}
+			t.logger.Debug("copied session cookies", zap.String("from", t.site.baseURL), zap.String("to", alias),
+				zap.Int("cookies", len(cookies)))
+		}
+		return nil
+	}
+}
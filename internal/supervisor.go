@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// errSessionLost indicates that chrome crashed, was closed or was logged out of X
+var errSessionLost = errors.New("browser session lost")
+
+// browserSession is a single browser along with the page used to drive it
+type browserSession struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	page     Page
+	crashed  atomic.Bool
+	recorder eventRecorder
+}
+
+// newBrowserSession launches the browser and opens the page that will be used to delete tweets
+func (t *TweetDeleter) newBrowserSession(ctx context.Context) (*browserSession, error) {
+	page, err := t.driver.NewPage()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s := &browserSession{ctx: ctx, cancel: cancel, page: page}
+
+	// Anything waiting on the page is abandoned as soon as it crashes or is closed
+	page.Listen(func(ev interface{}) {
+		if _, ok := ev.(*CrashEvent); ok {
+			s.crashed.Store(true)
+			s.cancel()
+		}
+	})
+	t.rateLimit.listen(page)
+	t.backend.listen(page)
+	s.recorder.listen(page)
+	return s, nil
+}
+
+// closeSession shuts down the browser of the current session, if there is one
+func (t *TweetDeleter) closeSession() {
+	if t.session != nil {
+		t.session.close()
+		t.session = nil
+	}
+}
+
+// close shuts down the browser
+func (s *browserSession) close() {
+	s.cancel()
+	s.page.Close()
+}
+
+// lost reports whether the browser or its page has gone away
+func (s *browserSession) lost() bool {
+	return s.crashed.Load() || s.page.Lost()
+}
+
+// loggedIn reports whether the browser has a logged in X session, such as one saved in its profile
+func (t *TweetDeleter) loggedIn(s *browserSession) bool {
+	ctx, cancel := context.WithTimeout(s.ctx, stepTimeout)
+	defer cancel()
+
+	if err := s.page.Navigate(ctx, t.site.homeURL()); err != nil {
+		return false
+	}
+	_, err := s.page.Find(ctx, "session.loggedIn", t.selectors.Session.LoggedIn, "", 10*time.Second)
+	return err == nil
+}
+
+// loggedOutScript implements ScriptLoggedOut
+var loggedOutScript = Script{Name: ScriptLoggedOut, Source: withLocate(`(loggedOut) => {
+	return location.pathname.startsWith("/i/flow/login") ||
+		location.pathname === "/login" ||
+		location.pathname.startsWith("/logout") ||
+		locate(loggedOut, "") !== null;
+}`)}
+
+// loggedOut reports whether X has logged the browser out, either by redirecting
+// to the login flow or by rendering its logged out UI
+func (s *browserSession) loggedOut(sel *SelectorProfile) bool {
+	ctx, cancel := quickCheck(s.ctx)
+	defer cancel()
+
+	var out bool
+	err := s.page.Evaluate(ctx, loggedOutScript, &out, sel.Session.LoggedOut)
+	return err == nil && out
+}
+
+// checkSessionLost inspects the browser after err occurred to determine if it was caused by
+// losing the browser session. If it was, the returned error wraps errSessionLost.
+func (t *TweetDeleter) checkSessionLost(s *browserSession, err error) error {
+	if s.lost() {
+		return fmt.Errorf("%w: chrome exited or its tab crashed: %w", errSessionLost, err)
+	}
+	if s.loggedOut(t.selectors) {
+		return fmt.Errorf("%w: logged out of x: %w", errSessionLost, err)
+	}
+	return err
+}
+
+// checkStepError determines whether a failed step was caused by losing the browser session or by X
+// rate limiting the account, wrapping err with errSessionLost or ErrRateLimited as appropriate. A debug
+// bundle is captured unless the account was rate limited, since those steps are retried and X's UI
+// isn't at fault.
+func (t *TweetDeleter) checkStepError(s *browserSession, err error) error {
+	if lost := t.checkSessionLost(s, err); errors.Is(lost, errSessionLost) {
+		return t.captureDebugBundle(s, lost)
+	}
+	if limited := t.checkRateLimited(s.ctx, s.page, err); errors.Is(limited, ErrRateLimited) {
+		return limited
+	}
+	return t.captureDebugBundle(s, err)
+}
@@ -0,0 +1,237 @@
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// archiveTweetsFile matches the files of an X data archive holding the account's tweets. Large archives
+// split them into parts, and older archives call them tweet.js.
+var archiveTweetsFile = regexp.MustCompile(`^tweets?(-part\d+)?\.js$`)
+
+// archiveMediaDirs are the directories of an X data archive holding the media attached to tweets, named
+// <tweet id>-<file name>. Older archives call it tweet_media.
+var archiveMediaDirs = []string{"data/tweets_media", "data/tweet_media"}
+
+// archiveMimeTypes are the types of the media files X puts in archives
+var archiveMimeTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".mp4":  "video/mp4",
+}
+
+// archivedTweet is a tweet read from an X data archive
+type archivedTweet struct {
+	ID        string
+	Text      string
+	CreatedAt time.Time
+	// Repost is set for reposts of other tweets, which X archives as text starting with "RT @"
+	Repost bool
+	// InReplyTo is the id of the tweet this replies to, if it is a reply
+	InReplyTo string
+	// Media are the paths of the files attached to the tweet within the archive
+	Media []string
+}
+
+// archivedMedia is a file attached to an archived tweet
+type archivedMedia struct {
+	Name     string
+	MimeType string
+	Data     []byte
+}
+
+// tweetArchive is an X data archive, as downloaded from Settings → Your account → Download an archive
+// of your data. It can be the zip file itself or the directory it was extracted to.
+type tweetArchive struct {
+	fsys   fs.FS
+	closer io.Closer
+	tweets map[string]archivedTweet
+}
+
+// openTweetArchive reads the tweets in the archive at path. The caller should close the archive when
+// finished so that the media of the tweets can be read in the meantime.
+func openTweetArchive(path string) (*tweetArchive, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open tweet archive: %w", err)
+	}
+	a := &tweetArchive{tweets: make(map[string]archivedTweet)}
+	if info.IsDir() {
+		a.fsys = os.DirFS(path)
+	} else {
+		z, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not open tweet archive: %w", err)
+		}
+		a.fsys, a.closer = z, z
+	}
+
+	if err := a.readTweets(); err != nil {
+		a.close()
+		return nil, fmt.Errorf("could not read tweet archive %s: %w", path, err)
+	}
+	return a, nil
+}
+
+// readTweets reads the archive's tweets along with the media attached to each of them
+func (a *tweetArchive) readTweets() error {
+	entries, err := fs.ReadDir(a.fsys, "data")
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, entry := range entries {
+		if entry.IsDir() || !archiveTweetsFile.MatchString(entry.Name()) {
+			continue
+		}
+		found = true
+		if err := a.readTweetsFile(path.Join("data", entry.Name())); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return errors.New("no tweets.js file found in the archive's data directory")
+	}
+
+	for _, dir := range archiveMediaDirs {
+		entries, err := fs.ReadDir(a.fsys, dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			id, _, ok := strings.Cut(entry.Name(), "-")
+			if tweet, exists := a.tweets[id]; ok && exists && !entry.IsDir() {
+				tweet.Media = append(tweet.Media, path.Join(dir, entry.Name()))
+				a.tweets[id] = tweet
+			}
+		}
+	}
+	for id, tweet := range a.tweets {
+		sort.Strings(tweet.Media)
+		a.tweets[id] = tweet
+	}
+	return nil
+}
+
+// readTweetsFile reads the tweets in one of the archive's tweets.js files. They're JavaScript assigning a
+// JSON array to a global, like window.YTD.tweets.part0 = [...].
+func (a *tweetArchive) readTweetsFile(name string) error {
+	data, err := fs.ReadFile(a.fsys, name)
+	if err != nil {
+		return err
+	}
+	if i := bytes.IndexByte(data, '='); i >= 0 {
+		data = data[i+1:]
+	}
+
+	var entries []struct {
+		Tweet struct {
+			ID        string `json:"id_str"`
+			FullText  string `json:"full_text"`
+			CreatedAt string `json:"created_at"`
+			InReplyTo string `json:"in_reply_to_status_id_str"`
+			Entities  struct {
+				URLs []struct {
+					URL         string `json:"url"`
+					ExpandedURL string `json:"expanded_url"`
+				} `json:"urls"`
+			} `json:"entities"`
+			ExtendedEntities struct {
+				Media []struct {
+					URL string `json:"url"`
+				} `json:"media"`
+			} `json:"extended_entities"`
+		} `json:"tweet"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("could not parse %s: %w", name, err)
+	}
+
+	for _, entry := range entries {
+		t := entry.Tweet
+		createdAt, err := time.Parse(time.RubyDate, t.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("could not parse the date of tweet %s in %s: %w", t.ID, name, err)
+		}
+
+		// Links are shortened with t.co, and attached media are linked at the end of the text
+		text := html.UnescapeString(t.FullText)
+		for _, u := range t.Entities.URLs {
+			text = strings.ReplaceAll(text, u.URL, u.ExpandedURL)
+		}
+		for _, m := range t.ExtendedEntities.Media {
+			text = strings.ReplaceAll(text, m.URL, "")
+		}
+		a.tweets[t.ID] = archivedTweet{
+			ID:        t.ID,
+			Text:      strings.TrimSpace(text),
+			CreatedAt: createdAt,
+			Repost:    strings.HasPrefix(t.FullText, "RT @"),
+			InReplyTo: t.InReplyTo,
+		}
+	}
+	return nil
+}
+
+// tweet returns the archived tweet with id
+func (a *tweetArchive) tweet(id string) (archivedTweet, bool) {
+	tweet, ok := a.tweets[id]
+	return tweet, ok
+}
+
+// between returns the archived tweets posted from since up to until, oldest first
+func (a *tweetArchive) between(since, until time.Time) []archivedTweet {
+	var tweets []archivedTweet
+	for _, tweet := range a.tweets {
+		if !tweet.CreatedAt.Before(since) && tweet.CreatedAt.Before(until) {
+			tweets = append(tweets, tweet)
+		}
+	}
+	sort.Slice(tweets, func(i, j int) bool {
+		if !tweets[i].CreatedAt.Equal(tweets[j].CreatedAt) {
+			return tweets[i].CreatedAt.Before(tweets[j].CreatedAt)
+		}
+		return tweets[i].ID < tweets[j].ID
+	})
+	return tweets
+}
+
+// media reads the files attached to tweet
+func (a *tweetArchive) media(tweet archivedTweet) ([]archivedMedia, error) {
+	media := make([]archivedMedia, 0, len(tweet.Media))
+	for _, name := range tweet.Media {
+		data, err := fs.ReadFile(a.fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("could not read media of tweet %s: %w", tweet.ID, err)
+		}
+		mimeType, ok := archiveMimeTypes[strings.ToLower(path.Ext(name))]
+		if !ok {
+			mimeType = "application/octet-stream"
+		}
+		media = append(media, archivedMedia{Name: path.Base(name), MimeType: mimeType, Data: data})
+	}
+	return media, nil
+}
+
+// close closes the archive's zip file, if it is one
+func (a *tweetArchive) close() error {
+	if a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
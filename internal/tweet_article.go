@@ -0,0 +1,256 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chromedp/chromedp/kb"
+)
+
+// pendingDeleteAttr marks the article that is currently being acted on so that its elements
+// can be found inside it and we can tell when X has removed it from the timeline
+const pendingDeleteAttr = "data-tweetdeleter-pending"
+
+// markFirstTweetScript implements ScriptMarkFirstTweet
+var markFirstTweetScript = Script{Name: ScriptMarkFirstTweet, Source: withLocate(`(tweet, permalink, attr) => {
+	const tweets = locate(tweet, "");
+	if (!tweets) {
+		return false;
+	}
+	document.querySelectorAll("[" + attr + "]").forEach((el) => el.removeAttribute(attr));
+	tweets.elements[0].setAttribute(attr, "");
+
+	// The tweet's timestamp links to the tweet itself
+	const links = locate(permalink, "[" + attr + "]")?.elements ?? [];
+	const link = links.find((a) => a.querySelector("time") !== null) ?? links[0];
+	const match = link?.getAttribute("href")?.match(/\/status\/(\d+)/);
+	return { id: match ? match[1] : "", index: tweets.index };
+}`)}
+
+// markTweetScript implements ScriptMarkTweet
+var markTweetScript = Script{Name: ScriptMarkTweet, Source: `(idAttr, id, attr) => {
+	const tweet = document.querySelector("[" + idAttr + "=\"" + CSS.escape(id) + "\"]");
+	if (!tweet) {
+		return false;
+	}
+	document.querySelectorAll("[" + attr + "]").forEach((el) => el.removeAttribute(attr));
+	tweet.setAttribute(attr, "");
+	return true;
+}`}
+
+// dropdownReadyScript implements ScriptDropdownReady
+var dropdownReadyScript = Script{Name: ScriptDropdownReady, Source: withLocate(`(dropdown, item) => {
+	const menu = locate(dropdown, "");
+	const items = locate(item, "");
+	if (!menu || !items || !menu.elements.some((el) => items.elements.some((i) => el.contains(i)))) {
+		return false;
+	}
+	const settled = menu.elements.every((el) =>
+		el.getAnimations({ subtree: true }).every((a) => a.playState !== "running"));
+	return settled ? { index: menu.index } : false;
+}`)}
+
+// tweetDeletedScript implements ScriptTweetDeleted
+var tweetDeletedScript = Script{Name: ScriptTweetDeleted, Source: withLocate(`(confirm, attr) => {
+	return locate(confirm, "") === null && document.querySelector("[" + attr + "]") === null;
+}`)}
+
+// tweetTextScript implements ScriptTweetText
+var tweetTextScript = Script{Name: ScriptTweetText, Source: withLocate(`(text, scope) => {
+	const found = locate(text, scope);
+	if (!found) {
+		return { text: "", index: -1 };
+	}
+	return { text: found.elements[0].innerText, index: found.index };
+}`)}
+
+// removeMarkedScript implements ScriptRemoveMarked
+var removeMarkedScript = Script{Name: ScriptRemoveMarked, Source: `(attr) => {
+	document.querySelector("[" + attr + "]")?.remove();
+	return true;
+}`}
+
+// tweetArticle is a tweet rendered on a timeline, such as search results or the account's profile.
+// It is the first tweet on the page unless it was collected from search results with its id. It must
+// be marked before anything inside it can be acted on.
+type tweetArticle struct {
+	screen
+	id string
+}
+
+// scope is the CSS selector of the marked article
+func (a tweetArticle) scope() string {
+	return "[" + pendingDeleteAttr + "]"
+}
+
+// mark flags the first tweet on the page as the one being acted on and stores its id in
+// tweetID, if the id could be found
+func (a tweetArticle) mark(tweetID *string) step {
+	return func(ctx context.Context, p Page) error {
+		var marked struct {
+			ID    string `json:"id"`
+			Index int    `json:"index"`
+		}
+		err := p.Poll(ctx, markFirstTweetScript, &marked, PollOptions{Mutation: true, Timeout: pageReadyTimeout},
+			a.selectors.Tweet.Article, a.selectors.Tweet.Permalink, pendingDeleteAttr)
+		if err != nil {
+			return fmt.Errorf("could not find a tweet: %w", err)
+		}
+
+		a.logFallback("tweet.article", a.selectors.Tweet.Article, marked.Index)
+		*tweetID = marked.ID
+		return nil
+	}
+}
+
+// markCollected flags the tweet collected from the results with its id as the one being acted on
+func (a tweetArticle) markCollected() step {
+	return func(ctx context.Context, p Page) error {
+		var found bool
+		if err := p.Evaluate(ctx, markTweetScript, &found, tweetIDAttr, a.id, pendingDeleteAttr); err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("could not find tweet %s in the results", a.id)
+		}
+		return nil
+	}
+}
+
+// isPinned stores whether the marked tweet is pinned to the top of its author's profile in pinned.
+// X only labels pinned tweets on profiles, so tweets in search results are never reported as pinned.
+func (a tweetArticle) isPinned(pinned *bool) step {
+	return func(ctx context.Context, p Page) error {
+		var n int
+		if err := count("tweet.pinnedLabel", a.selectors.Tweet.PinnedLabel, a.scope(), &n)(ctx, p); err != nil {
+			return err
+		}
+		*pinned = n > 0
+		return nil
+	}
+}
+
+// text stores the text of the marked tweet in text, which is empty if the tweet only has media
+func (a tweetArticle) text(text *string) step {
+	return func(ctx context.Context, p Page) error {
+		var shown struct {
+			Text  string `json:"text"`
+			Index int    `json:"index"`
+		}
+		if err := p.Evaluate(ctx, tweetTextScript, &shown, a.selectors.Tweet.Text, a.scope()); err != nil {
+			return err
+		}
+		if shown.Index >= 0 {
+			a.logFallback("tweet.text", a.selectors.Tweet.Text, shown.Index)
+		}
+		*text = shown.Text
+		return nil
+	}
+}
+
+// openMenu clicks the marked tweet's "More" button and waits for its menu to open
+func (a tweetArticle) openMenu() step {
+	return steps(
+		a.locate("tweet.moreButton", a.selectors.Tweet.MoreButton, a.scope()),
+		clickLocated("tweet.moreButton"),
+		a.menu().waitOpen(),
+	)
+}
+
+// menu returns the marked tweet's "More" menu
+func (a tweetArticle) menu() tweetMenu {
+	return tweetMenu{screen: a.screen}
+}
+
+// waitDeleted blocks until the confirmation sheet has closed and the marked tweet has been
+// removed from the page
+func (a tweetArticle) waitDeleted() step {
+	return func(ctx context.Context, p Page) error {
+		var deleted bool
+		err := p.Poll(ctx, tweetDeletedScript, &deleted, PollOptions{Mutation: true, Timeout: pageReadyTimeout},
+			a.selectors.Tweet.ConfirmButton, pendingDeleteAttr)
+		if err != nil {
+			return fmt.Errorf("tweet was never removed from the page: %w", err)
+		}
+		return nil
+	}
+}
+
+// delete deletes the marked tweet through its menu and waits for it to disappear
+func (a tweetArticle) delete() step {
+	return steps(
+		a.openMenu(),
+		a.menu().deleteItem(),
+		a.menu().confirmSheet().confirm(),
+		a.waitDeleted(),
+	)
+}
+
+// remove takes the marked tweet off the page without going through X, for tweets that were
+// deleted by other means
+func (a tweetArticle) remove() step {
+	return func(ctx context.Context, p Page) error {
+		var removed bool
+		return p.Evaluate(ctx, removeMarkedScript, &removed, pendingDeleteAttr)
+	}
+}
+
+// tweetMenu is the dropdown opened by a tweet's "More" button
+type tweetMenu struct {
+	screen
+}
+
+// waitOpen blocks until the menu has been rendered, contains menu items and has finished
+// animating into place. This is polled on every animation frame since animations finishing
+// do not trigger DOM mutations.
+func (m tweetMenu) waitOpen() step {
+	return func(ctx context.Context, p Page) error {
+		var ready struct {
+			Index int `json:"index"`
+		}
+		err := p.Poll(ctx, dropdownReadyScript, &ready, PollOptions{Timeout: pageReadyTimeout},
+			m.selectors.Tweet.Menu, m.selectors.Tweet.MenuItem)
+		if err != nil {
+			return fmt.Errorf("tweet menu never became interactive: %w", err)
+		}
+
+		m.logFallback("tweet.menu", m.selectors.Tweet.Menu, ready.Index)
+		return nil
+	}
+}
+
+// deleteItem clicks the menu's "Delete" item. The item is only clicked if one of its locators
+// positively identifies it, since the other items in the menu, like "Pin to your profile", are
+// not something we want to click by mistake.
+func (m tweetMenu) deleteItem() step {
+	return func(ctx context.Context, p Page) error {
+		err := m.click("tweet.deleteMenuItem", m.selectors.Tweet.DeleteMenuItem)(ctx, p)
+		if err != nil && ctx.Err() == nil {
+			return fmt.Errorf("refusing to click: could not positively identify the delete menu item "+
+				"for this page's language. add a locator for it to the selector profile: %w", err)
+		}
+		return err
+	}
+}
+
+// close closes the menu without choosing anything
+func (m tweetMenu) close() step {
+	return func(ctx context.Context, p Page) error {
+		return p.PressKey(ctx, kb.Escape)
+	}
+}
+
+// confirmSheet returns the sheet asking to confirm the item chosen from the menu
+func (m tweetMenu) confirmSheet() confirmSheet {
+	return confirmSheet{screen: m.screen}
+}
+
+// confirmSheet is the sheet X shows to confirm deleting a tweet
+type confirmSheet struct {
+	screen
+}
+
+// confirm clicks the sheet's confirm button
+func (c confirmSheet) confirm() step {
+	return c.click("tweet.confirmButton", c.selectors.Tweet.ConfirmButton)
+}
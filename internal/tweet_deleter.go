@@ -2,24 +2,44 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"time"
 
-	"github.com/chromedp/cdproto/cdp"
-	"github.com/chromedp/cdproto/runtime"
-	"github.com/chromedp/chromedp"
-	"github.com/chromedp/chromedp/kb"
 	"go.uber.org/zap"
 )
 
-// TweetDeleter deletes all tweets based on the parameters provided
+const (
+	// stepTimeout bounds how long a single step against the browser may take before it is considered failed
+	stepTimeout = 2 * time.Minute
+	// searchWindow is the length of the windows searched for tweets. Larger windows, like a year, tend to
+	// not return all available tweets.
+	searchWindow = 7 * 24 * time.Hour
+	// maxCollectedTweets is the most tweets taken from the search results at a time
+	maxCollectedTweets = 100
+)
+
+// TweetDeleter deletes all tweets based on the parameters provided. It is the Platform for X, driving
+// chrome unless the official API is used.
 type TweetDeleter struct {
+	screen
 	username  string
 	password  string
 	startDate time.Time
 	endDate   time.Time
-	logger    *zap.Logger
+	debugDir  string
+	site      site
+	chrome    chromeOptions
+	driver    Driver
+	rateLimit *rateLimitMonitor
+	backend   deleteBackend
+	api       *apiClient
+	migration *migration
+	// interactions are the reposts and likes undone after the tweets are deleted in DeleteModeHTTP
+	interactions *interactions
+	session      *browserSession
+	collected    []string        // ids of the tweets found by the last search
+	deleted      map[string]bool // ids of the tweets deleted so far
 }
 
 type TweetDeleterOptions struct {
@@ -28,143 +48,247 @@ type TweetDeleterOptions struct {
 	StartDate time.Time
 	EndDate   time.Time
 	Logger    *zap.Logger
+	// DebugDir is where debug bundles are written when a step fails. Debug bundles are
+	// not written if DebugDir is empty.
+	DebugDir string
+	// Selectors are the selectors used to find elements on X's pages.
+	// The embedded default profile is used if Selectors is nil.
+	Selectors *SelectorProfile
+	// UserDataDir is the chrome profile directory to use. Setting it saves the X session
+	// between runs so that logging in can be skipped while the session is still valid.
+	// A temporary profile is used if UserDataDir is empty.
+	UserDataDir string
+	// Headless runs chrome without a visible window
+	Headless bool
+	// LoadMedia lets chrome load images, video, fonts and analytics. They are blocked by default
+	// since none of them are needed to delete tweets and they make X's pages slow to load.
+	LoadMedia bool
+	// Record is a file to write the CDP traffic between chromedp and chrome to, along with snapshots
+	// of the page's DOM after each action, so that the run can be replayed with NewReplayDriver. The
+	// recording contains the session's cookies and everything typed, including the password, so
+	// recording with a saved session in UserDataDir is recommended. Nothing is recorded if Record is
+	// empty or Driver is set.
+	Record string
+	// Driver launches the browser used to delete tweets. Chrome, configured by UserDataDir, Headless,
+	// LoadMedia and Record, is used if Driver is nil.
+	Driver Driver
+	// DeleteMode is how tweets are deleted once found. DeleteModeHTTP reuses the logged in browser's
+	// cookies and CSRF token to call X's API directly. DeleteModeAPI doesn't use chrome, or Username
+	// and Password, and is configured by API. DeleteModeUI is used if DeleteMode is empty.
+	DeleteMode DeleteMode
+	// API configures DeleteModeAPI
+	API APIOptions
+	// Likes also removes the account's likes of tweets posted in the time range in DeleteModeHTTP,
+	// which always undoes the account's reposts. DeleteModeAPI uses API.Likes instead.
+	Likes bool
+	// Migrate copies each tweet to a Mastodon or Bluesky account, and only deletes it once the copy
+	// is confirmed. Tweets are deleted without being copied if Migrate.Archive is empty.
+	Migrate MigrateOptions
+	// BaseURL is the scheme and host of the site to delete tweets from, such as a mirror or a
+	// local stand-in for X served by the fakex package. https://x.com is used if BaseURL is
+	// empty. The session is shared with twitter.com when BaseURL is one of X's domains.
+	BaseURL string
 }
 
 // NewTweetDeleter creates a new TweetDeleter object
 func NewTweetDeleter(opts TweetDeleterOptions) (*TweetDeleter, error) {
-	return &TweetDeleter{
+	selectors := opts.Selectors
+	if selectors == nil {
+		selectors = DefaultSelectorProfile()
+	}
+	site, err := newSite(opts.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	chrome := chromeOptions{userDataDir: opts.UserDataDir, headless: opts.Headless, loadMedia: opts.LoadMedia}
+	if opts.Record != "" {
+		chrome.recorder = newCDPRecorder(opts.Record)
+	}
+	driver := opts.Driver
+	if driver == nil {
+		driver = &chromeDriver{opts: chrome}
+	}
+	rateLimit := &rateLimitMonitor{}
+	var backend deleteBackend
+	var api *apiClient
+	if opts.DeleteMode == DeleteModeAPI {
+		api, err = newAPIClient(opts.API, opts.Logger, rateLimit)
+	} else {
+		backend, err = newDeleteBackend(opts.DeleteMode, site, rateLimit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var migration *migration
+	if opts.Migrate.Archive != "" {
+		if migration, err = newMigration(opts.Migrate, opts.StartDate, opts.EndDate, opts.Logger, rateLimit); err != nil {
+			return nil, err
+		}
+	}
+
+	t := &TweetDeleter{
 		username:  opts.Username,
 		password:  opts.Password,
 		startDate: opts.StartDate,
 		endDate:   opts.EndDate,
-		logger:    opts.Logger,
-	}, nil
+		screen:    screen{selectors: selectors, logger: opts.Logger},
+		debugDir:  opts.DebugDir,
+		site:      site,
+		chrome:    chrome,
+		driver:    driver,
+		rateLimit: rateLimit,
+		backend:   backend,
+		api:       api,
+		migration: migration,
+		deleted:   make(map[string]bool),
+	}
+	if b, ok := backend.(*graphqlBackend); ok {
+		t.interactions = &interactions{t: t, client: b.client, likes: opts.Likes}
+	}
+	return t, nil
 }
 
 // Run starts the tweet deletion process. Run executes until
-// all tweets are deleted or a fatal error occurs.
+// all tweets are deleted or a fatal error occurs. If chrome crashes or
+// X logs us out, chrome is relaunched and deletion resumes from the
+// last window that wasn't fully deleted. In DeleteModeAPI, the API is used instead of chrome.
+// When migrating, each tweet is copied before it is deleted. In DeleteModeHTTP, the account's reposts,
+// and its likes if asked to, are undone once its tweets are deleted.
 func (t *TweetDeleter) Run() error {
-	// Create chrome instance
-	ctx, cancel := chromedp.NewExecAllocator(
-		context.Background(),
-		append(chromedp.DefaultExecAllocatorOptions[:],
-			chromedp.Flag("headless", false),
-			chromedp.Flag("auto-open-devtools-for-tabs", false))...,
-	)
-	ctx, cancel = chromedp.NewContext(ctx, chromedp.WithLogf(log.Printf))
-	defer cancel()
-
-	// Login to x.com
-	if err := chromedp.Run(ctx, t.login()); err != nil {
-		return fmt.Errorf("error while attempting to login: %w", err)
+	p := &purge{
+		platform:  t,
+		logger:    t.logger,
+		rateLimit: t.rateLimit,
+		endDate:   t.endDate,
+		window:    searchWindow,
+		noun:      "tweets",
+		progress:  progress{since: t.startDate},
+	}
+	if t.api != nil {
+		p.platform, p.window = t.api, 0
+	} else {
+		defer t.closeSession()
+	}
+	if t.migration != nil {
+		defer t.migration.close()
+		t.migration.Platform, p.platform = p.platform, t.migration
+	}
+	if err := p.run(context.Background()); err != nil || t.interactions == nil {
+		return err
 	}
-	t.logger.Info("successfully logged in", zap.String("username", t.username))
-
-	// Search and delete tweets in 7 day chunks. Larger chunks, like a year, tend to not return
-	// all available tweets
-	for since, until := t.startDate, t.startDate; until.Before(t.endDate); since = until {
-		until = since.Add(7 * 86400 * time.Second) // 7 days
-		if until.After(t.endDate) {
-			until = t.endDate
-		}
 
-		// Search provided date range
-		if err := chromedp.Run(ctx, t.searchTweets(since, until)); err != nil {
-			return fmt.Errorf("error while attempting to search for tweets: %w", err)
-		}
-		t.logger.Info("searched for latest tweets",
-			zap.Time("startDate", since), zap.Time("endDate", until))
-
-		// Check to see if search yielding any results
-		var emptyState bool
-		err := chromedp.Run(ctx,
-			// Give the search results some time to load.
-			// TODO: Have a more reliable check here that's not just waiting
-			chromedp.Sleep(3*time.Second),
-			// This is very hacky. There's not a clean way to just check if an element exists so
-			// we query for a selector, indicate that we're ok with 0 elements being returned so we don't wait
-			// indefinitely, and then we add a custom wait QueryOption to see how many elements were found.
-			// Either way, we return a non-nil slice of nodes so that the check doesn't repeat indefinitely.
-			chromedp.Query(
-				"div[data-testid=\"emptyState\"]",
-				chromedp.AtLeast(0),
-				chromedp.WaitFunc(func(ctx context.Context, frame *cdp.Frame, id runtime.ExecutionContextID, nid ...cdp.NodeID) ([]*cdp.Node, error) {
-					if len(nid) > 0 {
-						emptyState = true
-					}
-					return []*cdp.Node{}, nil
-				}),
-			))
-		if err != nil {
-			return fmt.Errorf("error checking if search returned tweets: %w", err)
-		}
+	p = &purge{
+		platform:  t.interactions,
+		logger:    t.logger,
+		rateLimit: t.rateLimit,
+		endDate:   t.endDate,
+		noun:      "interactions",
+		progress:  progress{since: t.startDate},
+	}
+	return p.run(context.Background())
+}
 
-		if emptyState {
-			t.logger.Info("no tweets found for time range. skipping to next",
-				zap.Time("startDate", since), zap.Time("endDate", until))
-			continue
-		}
+// Login launches chrome and logs into X, unless the saved session is still logged in. The browser
+// of a session that was lost is closed first.
+func (t *TweetDeleter) Login(ctx context.Context) error {
+	t.closeSession()
+	s, err := t.newBrowserSession(ctx)
+	if err != nil {
+		return err
+	}
+	t.session = s
 
-		// Loop through tweets and delete them
-		t.logger.Info("commencing deleting tweets...")
-		for i := 1; ; i++ {
-			var tweets []*cdp.Node
-			if err := chromedp.Run(ctx, chromedp.Nodes("article[data-testid=\"tweet\"]", &tweets)); err != nil {
-				return fmt.Errorf("failed to retrieve tweets for deleting: %w", err)
-			}
-
-			if err := chromedp.Run(ctx, t.deleteTweet()); err != nil {
-				return err
-			}
-
-			if i%10 == 0 {
-				t.logger.Info(fmt.Sprintf("%d tweets deleted", i))
-			}
-
-			// We've deleted our last tweet so let's bail
-			if len(tweets) == 1 {
-				t.logger.Info("no more tweets to delete from provided time range", zap.Int("tweetsDeleted", i))
-				break
-			}
+	// Login to x.com, unless the saved session is still logged in
+	if t.chrome.userDataDir != "" && t.loggedIn(s) {
+		t.logger.Info("using saved session", zap.String("userDataDir", t.chrome.userDataDir))
+	} else {
+		if err := runStep(s.ctx, s.page, t.loginPage().logIn(t.username, t.password)); err != nil {
+			return t.checkLoginError(s, fmt.Errorf("error while attempting to login: %w", err))
 		}
+		t.logger.Info("successfully logged in", zap.String("username", t.username))
 	}
 
+	// X links and redirects between x.com and twitter.com, so share the session with both
+	if err := runStep(s.ctx, s.page, t.syncCookies()); err != nil {
+		t.logger.Warn("could not share session cookies with the site's other domains", zap.Error(err))
+	}
 	return nil
 }
 
-func (t *TweetDeleter) login() chromedp.Tasks {
-	return chromedp.Tasks{
-		chromedp.Navigate("https://twitter.com/i/flow/login"),
-		chromedp.Click("input[name=\"text\"]", chromedp.NodeVisible),
-		chromedp.SendKeys("input[name=\"text\"]", t.username),
-		chromedp.Sleep(1 * time.Second), // NB: this may be unnecessary
-		chromedp.Click("div[role=\"button\"]:nth-of-type(6)"),
-		chromedp.Click("input[name=\"password\"]", chromedp.NodeVisible),
-		chromedp.SendKeys("input[name=\"password\"]", t.password),
-		chromedp.Click("div[data-testid=\"LoginForm_Login_Button\"]"),
-		chromedp.WaitVisible("a[href=\"/explore\"]"),
+// Enumerate searches for the tweets posted in window and returns the ones the results have rendered,
+// leaving out any that were already deleted. X loads more results as tweets are deleted, so the rest
+// are found by searching again.
+func (t *TweetDeleter) Enumerate(ctx context.Context, window Window) ([]Item, error) {
+	s := t.session
+	if err := runStep(s.ctx, s.page, t.searchPage().searchTweets(t.username, window.Since, window.Until)); err != nil {
+		return nil, t.checkStepError(s, fmt.Errorf("error while attempting to search for tweets: %w", err))
+	}
+	t.logger.Info("searched for latest tweets",
+		zap.Time("startDate", window.Since), zap.Time("endDate", window.Until))
+
+	// Wait for the search to either render tweets or tell us there aren't any
+	var result searchResult
+	if err := runStep(s.ctx, s.page, t.searchPage().waitForResults(&result)); err != nil {
+		return nil, t.checkStepError(s, fmt.Errorf("error checking if search returned tweets: %w", err))
+	}
+	switch result {
+	case searchResultEmpty:
+		return nil, nil
+	case searchResultError:
+		return nil, t.checkStepError(s, errors.New("x failed to load search results"))
+	}
+
+	var ids []string
+	if err := runStep(s.ctx, s.page, t.searchPage().collectTweets(maxCollectedTweets, tweetIDAttr, &ids)); err != nil {
+		return nil, t.checkStepError(s, fmt.Errorf("failed to retrieve tweets for deleting: %w", err))
 	}
+	// X's search can keep showing tweets for a while after they're deleted, even after logging in again
+	t.collected = ids[:0]
+	items := make([]Item, 0, len(ids))
+	for _, id := range ids {
+		if !t.deleted[id] {
+			t.collected = append(t.collected, id)
+			items = append(items, Item{ID: id, Kind: ItemPost})
+		}
+	}
+	return items, nil
 }
 
-func (t *TweetDeleter) searchTweets(since, until time.Time) chromedp.Tasks {
-	return chromedp.Tasks{
-		chromedp.Navigate("https://twitter.com/explore"),
-		chromedp.Click("input[data-testid=\"SearchBox_Search_Input\"]"),
-		chromedp.SendKeys("input[data-testid=\"SearchBox_Search_Input\"]",
-			fmt.Sprintf(
-				"from:%s since:%s until:%s"+kb.Enter, t.username, since.Format(time.DateOnly), until.Format(time.DateOnly),
-			)),
-		chromedp.Click("a[href*=\"live\"][role=\"tab\"]", chromedp.NodeVisible), // Click the "Latest" tab
+// Delete deletes the tweet from the search results with the delete backend. Backends that delete in
+// batches delete the tweets collected after it too, which are skipped when their turn comes.
+func (t *TweetDeleter) Delete(ctx context.Context, item Item) error {
+	if t.deleted[item.ID] {
+		return nil
+	}
+	// Tweets being migrated are deleted one at a time, since the ones after it haven't been copied yet
+	var queued []string
+	for i, id := range t.collected {
+		if id == item.ID && t.migration == nil {
+			queued = t.collected[i+1:]
+			break
+		}
+	}
+
+	ids, err := t.backend.deleteTweet(t.session, t.searchPage(), item.ID, queued)
+	for _, id := range ids {
+		t.deleted[id] = true
+	}
+	if err != nil {
+		return t.checkStepError(t.session, err)
 	}
+	return nil
 }
 
-func (t *TweetDeleter) deleteTweet() chromedp.Tasks {
-	return chromedp.Tasks{
-		chromedp.Click("article div[aria-label=\"More\"]"),
-		chromedp.Sleep(1 * time.Second), // this worked -- for some reason the wait for visible condition below didn't quite work
-		chromedp.WaitVisible("div[data-testid=\"Dropdown\"]"),
-		chromedp.Click("div[role=\"menuitem\"]:first-child"),
-		chromedp.WaitVisible("div[role=\"button\"][data-testid=\"confirmationSheetConfirm\"]"),
-		chromedp.Click("div[role=\"button\"][data-testid=\"confirmationSheetConfirm\"]"),
+// runStep runs steps against the page, failing if they don't complete within stepTimeout.
+// Steps that time out waiting on the page are reported as ErrSelectorMissing.
+func runStep(ctx context.Context, p Page, ss ...step) error {
+	stepCtx, cancel := context.WithTimeout(ctx, stepTimeout)
+	defer cancel()
+
+	err := steps(ss...)(stepCtx, p)
+	if err != nil && ctx.Err() == nil &&
+		(errors.Is(stepCtx.Err(), context.DeadlineExceeded) || errors.Is(err, ErrWaitTimeout)) {
+		return fmt.Errorf("%w: %w", ErrSelectorMissing, err)
 	}
+	return err
 }
@@ -0,0 +1,205 @@
+package internal_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"tweetdeleter/internal"
+	"tweetdeleter/internal/fakex"
+)
+
+var (
+	runSince = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	runUntil = time.Date(2023, 2, 5, 0, 0, 0, 0, time.UTC)
+)
+
+// runFakeX runs a TweetDeleter over runSince to runUntil against site in memory, returning the logs
+// it wrote and Run's error
+func runFakeX(t *testing.T, site *fakex.Site) (*observer.ObservedLogs, error) {
+	t.Helper()
+	return runFakeXWith(t, site, internal.TweetDeleterOptions{})
+}
+
+// runFakeXWith is runFakeX with the rest of the options, such as the delete mode, set by opts
+func runFakeXWith(t *testing.T, site *fakex.Site, opts internal.TweetDeleterOptions) (*observer.ObservedLogs, error) {
+	t.Helper()
+	core, logs := observer.New(zap.InfoLevel)
+	opts.Username, opts.Password = "fake", "secret"
+	opts.StartDate, opts.EndDate = runSince, runUntil
+	opts.Logger = zap.New(core)
+	opts.Driver = fakex.NewDriver(site)
+	td, err := internal.NewTweetDeleter(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = td.Run()
+	return logs, err
+}
+
+// checkDeletedOnce fails the test unless every tweet in want was deleted exactly once and nothing else was
+func checkDeletedOnce(t *testing.T, site *fakex.Site, want []fakex.Tweet) {
+	t.Helper()
+	seen := make(map[string]int)
+	for _, id := range site.Deleted() {
+		seen[id]++
+	}
+	for _, tweet := range want {
+		if seen[tweet.ID] != 1 {
+			t.Errorf("tweet %s was deleted %d times, want once", tweet.ID, seen[tweet.ID])
+		}
+		delete(seen, tweet.ID)
+	}
+	for id := range seen {
+		t.Errorf("tweet %s was deleted but is outside the time range", id)
+	}
+}
+
+// checkReportedCount fails the test unless Run finished by reporting that want tweets were deleted
+func checkReportedCount(t *testing.T, logs *observer.ObservedLogs, want int) {
+	t.Helper()
+	finished := logs.FilterMessage("finished deleting tweets").All()
+	if len(finished) != 1 {
+		t.Fatalf("logged %d finished messages, want 1", len(finished))
+	}
+	if got := finished[0].ContextMap()["tweetsDeleted"]; got != int64(want) {
+		t.Errorf("reported %v tweets deleted, want %d", got, want)
+	}
+}
+
+func TestRunSearchesEachWeek(t *testing.T) {
+	inRange := fakex.GenerateTweets(50, runSince, runUntil)
+	before := fakex.Tweet{ID: "1", Text: "before", CreatedAt: runSince.Add(-time.Hour)}
+	after := fakex.Tweet{ID: "2", Text: "after", CreatedAt: runUntil}
+	site := fakex.NewSite(fakex.Options{
+		Username: "fake",
+		Password: "secret",
+		Tweets:   append(inRange, before, after),
+	})
+
+	logs, err := runFakeX(t, site)
+	if err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+
+	// The time range is five weeks, so it takes five searches that each stop short of the next one
+	var windows []internal.Window
+	for _, e := range logs.FilterMessage("searched for latest tweets").All() {
+		w := internal.Window{Since: e.ContextMap()["startDate"].(time.Time), Until: e.ContextMap()["endDate"].(time.Time)}
+		if len(windows) == 0 || windows[len(windows)-1] != w {
+			windows = append(windows, w)
+		}
+	}
+	if len(windows) != 5 {
+		t.Fatalf("searched %d windows, want 5: %v", len(windows), windows)
+	}
+	for i, w := range windows {
+		since := runSince.AddDate(0, 0, 7*i)
+		if !w.Since.Equal(since) || !w.Until.Equal(since.AddDate(0, 0, 7)) {
+			t.Errorf("window %d is %s to %s, want the week starting %s", i, w.Since, w.Until, since)
+		}
+	}
+
+	checkDeletedOnce(t, site, inRange)
+	if left := site.Tweets(); len(left) != 2 {
+		t.Errorf("%d tweets left, want the 2 outside the time range", len(left))
+	}
+	checkReportedCount(t, logs, len(inRange))
+}
+
+// Logging out part way through a week logs in again and searches that week again
+func TestRunResumesAfterLoggingOut(t *testing.T) {
+	tweets := fakex.GenerateTweets(50, runSince, runUntil)
+	site := fakex.NewSite(fakex.Options{
+		Username:    "fake",
+		Password:    "secret",
+		Tweets:      tweets,
+		LogOutAfter: 15,
+	})
+
+	logs, err := runFakeX(t, site)
+	if err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+
+	lost := logs.FilterMessage("lost session. logging in again and resuming").All()
+	if len(lost) != 1 {
+		t.Fatalf("lost the session %d times, want once", len(lost))
+	}
+	// The first week has 10 tweets, so the 15th is deleted in the second
+	if got, want := lost[0].ContextMap()["resumeFrom"], runSince.AddDate(0, 0, 7); got != want {
+		t.Errorf("resumed from %v, want %v", got, want)
+	}
+	if logins := logs.FilterMessage("successfully logged in").Len(); logins != 2 {
+		t.Errorf("logged in %d times, want 2", logins)
+	}
+	checkDeletedOnce(t, site, tweets)
+	checkReportedCount(t, logs, len(tweets))
+}
+
+// Tweets that search keeps showing after they're deleted, including after logging in again, are
+// neither deleted nor counted twice
+func TestRunSkipsTweetsShownAgainAfterDeleting(t *testing.T) {
+	tweets := fakex.GenerateTweets(50, runSince, runUntil)
+	site := fakex.NewSite(fakex.Options{
+		Username:    "fake",
+		Password:    "secret",
+		Tweets:      tweets,
+		SearchLag:   true,
+		LogOutAfter: 15,
+	})
+
+	logs, err := runFakeX(t, site)
+	if err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+	if left := site.Tweets(); len(left) != 0 {
+		t.Errorf("%d tweets left, want 0", len(left))
+	}
+	checkDeletedOnce(t, site, tweets)
+	checkReportedCount(t, logs, len(tweets))
+}
+
+// When the delete menu item can't be identified in the UI's language, the run stops instead of
+// clicking another item in the menu, and nothing is deleted
+func TestRunRefusesToDeleteInUnknownLanguage(t *testing.T) {
+	tweets := fakex.GenerateTweets(5, runSince, runUntil)
+	site := fakex.NewSite(fakex.Options{Username: "fake", Password: "secret", Tweets: tweets, Lang: "sv"})
+
+	_, err := runFakeX(t, site)
+	if !errors.Is(err, internal.ErrSelectorMissing) || !strings.Contains(err.Error(), "refusing to click") {
+		t.Fatalf("Run() = %v, want a refusal to click the delete menu item", err)
+	}
+	if deleted := site.Deleted(); len(deleted) != 0 {
+		t.Errorf("deleted %v, want nothing deleted", deleted)
+	}
+	if left := site.Tweets(); len(left) != len(tweets) {
+		t.Errorf("%d tweets left, want %d", len(left), len(tweets))
+	}
+}
+
+// Batch mode deletes weeks with more tweets than fit in one batch, and resumes after logging out part
+// way through a batch without deleting or counting anything twice
+func TestRunDeletesInBatches(t *testing.T) {
+	tweets := fakex.GenerateTweets(150, runSince, runUntil)
+	site := fakex.NewSite(fakex.Options{
+		Username:    "fake",
+		Password:    "secret",
+		Tweets:      tweets,
+		LogOutAfter: 45,
+	})
+
+	logs, err := runFakeXWith(t, site, internal.TweetDeleterOptions{DeleteMode: internal.DeleteModeBatch})
+	if err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+	if n := logs.FilterMessage("lost session. logging in again and resuming").Len(); n != 1 {
+		t.Errorf("lost the session %d times, want once", n)
+	}
+	checkDeletedOnce(t, site, tweets)
+	checkReportedCount(t, logs, len(tweets))
+}
@@ -0,0 +1,309 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultAPIBaseURL is the scheme and host of the official X API
+const defaultAPIBaseURL = "https://api.x.com"
+
+// APIOptions configure DeleteModeAPI, which deletes tweets through the official X API v2 instead of
+// driving chrome
+type APIOptions struct {
+	// ClientID is the OAuth 2.0 client id of an X app with the callback URL
+	// http://<CallbackAddr>/callback registered
+	ClientID string
+	// ClientSecret is the app's client secret. It is only needed for confidential clients.
+	ClientSecret string
+	// CallbackAddr is the local address X redirects to once the app is authorized.
+	// 127.0.0.1:8723 is used if CallbackAddr is empty.
+	CallbackAddr string
+	// TokenFile is where the access and refresh tokens are saved so that the app only has to be
+	// authorized once. The app is authorized on every run if TokenFile is empty.
+	TokenFile string
+	// OpenURL is called with the authorization URL when the app needs to be authorized, such as to
+	// open it in a browser. The URL is always logged.
+	OpenURL func(authURL string) error
+	// BaseURL is the scheme and host of the API, such as a local mock served by fakex.NewAPI. The
+	// authorization page is served from BaseURL too when it is set. https://api.x.com is used if
+	// BaseURL is empty.
+	BaseURL string
+	// Likes also removes the account's likes of tweets posted in the time range
+	Likes bool
+}
+
+// apiClient calls the endpoints of the X API v2 used to find and delete the account's tweets. It is
+// the Platform for DeleteModeAPI.
+type apiClient struct {
+	baseURL   string
+	http      *http.Client
+	auth      *oauthClient
+	rateLimit *rateLimitMonitor
+	likes     bool
+	userID    string // id of the authorized account, looked up by Login
+}
+
+// newAPIClient creates a client configured by opts, recording throttled calls in rateLimit
+func newAPIClient(opts APIOptions, logger *zap.Logger, rateLimit *rateLimitMonitor) (*apiClient, error) {
+	if opts.ClientID == "" {
+		return nil, errors.New("a client id is required to use the x api")
+	}
+	baseURL, authorizeURL := defaultAPIBaseURL, defaultAuthorizeURL
+	if opts.BaseURL != "" {
+		u, err := url.Parse(opts.BaseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid api base url %q", opts.BaseURL)
+		}
+		baseURL = strings.TrimSuffix(opts.BaseURL, "/")
+		authorizeURL = baseURL + "/i/oauth2/authorize"
+	}
+	callbackAddr := opts.CallbackAddr
+	if callbackAddr == "" {
+		callbackAddr = defaultCallbackAddr
+	}
+
+	httpClient := &http.Client{Timeout: stepTimeout}
+	return &apiClient{
+		baseURL: baseURL,
+		http:    httpClient,
+		auth: &oauthClient{
+			clientID:     opts.ClientID,
+			clientSecret: opts.ClientSecret,
+			authorizeURL: authorizeURL,
+			tokenURL:     baseURL + "/2/oauth2/token",
+			callbackAddr: callbackAddr,
+			tokenFile:    opts.TokenFile,
+			openURL:      opts.OpenURL,
+			http:         httpClient,
+			logger:       logger,
+		},
+		rateLimit: rateLimit,
+		likes:     opts.Likes,
+	}, nil
+}
+
+// apiTweet is a tweet returned by the API
+type apiTweet struct {
+	ID               string    `json:"id"`
+	CreatedAt        time.Time `json:"created_at"`
+	ReferencedTweets []struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	} `json:"referenced_tweets"`
+}
+
+// repostOf returns the id of the tweet this reposts, or "" if it isn't a repost
+func (t apiTweet) repostOf() string {
+	for _, ref := range t.ReferencedTweets {
+		if ref.Type == "retweeted" {
+			return ref.ID
+		}
+	}
+	return ""
+}
+
+// apiTweetPage is a page of tweets along with the token of the next page, which is empty on the last page
+type apiTweetPage struct {
+	Data []apiTweet `json:"data"`
+	Meta struct {
+		NextToken string `json:"next_token"`
+	} `json:"meta"`
+}
+
+// me returns the id of the authorized account
+func (c *apiClient) me(ctx context.Context) (string, error) {
+	var res struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/2/users/me", nil, &res); err != nil {
+		return "", err
+	}
+	return res.Data.ID, nil
+}
+
+// userTweets returns a page of the tweets and reposts userID posted between since and until, newest first
+func (c *apiClient) userTweets(ctx context.Context, userID string, since, until time.Time, token string) (*apiTweetPage, error) {
+	q := url.Values{
+		"max_results":  {"100"},
+		"start_time":   {since.UTC().Format(time.RFC3339)},
+		"end_time":     {until.UTC().Format(time.RFC3339)},
+		"tweet.fields": {"created_at,referenced_tweets"},
+	}
+	if token != "" {
+		q.Set("pagination_token", token)
+	}
+	var page apiTweetPage
+	err := c.do(ctx, http.MethodGet, "/2/users/"+url.PathEscape(userID)+"/tweets", q, &page)
+	return &page, err
+}
+
+// likedTweets returns a page of the tweets userID has liked, most recently liked first
+func (c *apiClient) likedTweets(ctx context.Context, userID, token string) (*apiTweetPage, error) {
+	q := url.Values{"max_results": {"100"}, "tweet.fields": {"created_at"}}
+	if token != "" {
+		q.Set("pagination_token", token)
+	}
+	var page apiTweetPage
+	err := c.do(ctx, http.MethodGet, "/2/users/"+url.PathEscape(userID)+"/liked_tweets", q, &page)
+	return &page, err
+}
+
+// deleteTweet deletes the tweet with id
+func (c *apiClient) deleteTweet(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/2/tweets/"+url.PathEscape(id), nil, nil)
+}
+
+// unlike removes userID's like of the tweet with id
+func (c *apiClient) unlike(ctx context.Context, userID, id string) error {
+	return c.do(ctx, http.MethodDelete, "/2/users/"+url.PathEscape(userID)+"/likes/"+url.PathEscape(id), nil, nil)
+}
+
+// unrepost undoes userID's repost of the tweet with id
+func (c *apiClient) unrepost(ctx context.Context, userID, id string) error {
+	return c.do(ctx, http.MethodDelete, "/2/users/"+url.PathEscape(userID)+"/retweets/"+url.PathEscape(id), nil, nil)
+}
+
+// do calls the endpoint at path and decodes its response into res. A call rejected because the access
+// token expired is retried once with a refreshed token.
+func (c *apiClient) do(ctx context.Context, method, path string, query url.Values, res interface{}) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var rejected string
+	for {
+		token, err := c.auth.accessToken(ctx, rejected)
+		if err != nil {
+			return fmt.Errorf("could not authorize with the x api: %w", err)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, u, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return fmt.Errorf("%s %s failed: %w", method, path, err)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("%s %s failed: %w", method, path, err)
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized && rejected == "":
+			rejected = token
+			continue
+		case resp.StatusCode == http.StatusTooManyRequests:
+			c.rateLimit.throttledResponse(resp)
+			return fmt.Errorf("%w: %s %s: %s", ErrRateLimited, method, path, resp.Status)
+		case resp.StatusCode < 200 || resp.StatusCode > 299:
+			return fmt.Errorf("%s %s failed: %s: %s", method, path, resp.Status, apiErrorDetail(data))
+		}
+		if res == nil {
+			return nil
+		}
+		if err := json.Unmarshal(data, res); err != nil {
+			return fmt.Errorf("%s %s returned an unexpected response: %w", method, path, err)
+		}
+		return nil
+	}
+}
+
+// apiErrorDetail returns the explanation of an error response from the API
+func apiErrorDetail(data []byte) string {
+	var body struct {
+		Detail string `json:"detail"`
+		Title  string `json:"title"`
+	}
+	if json.Unmarshal(data, &body) == nil {
+		if body.Detail != "" {
+			return body.Detail
+		}
+		if body.Title != "" {
+			return body.Title
+		}
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Login looks up the id of the authorized account, authorizing the app first if there isn't a token yet
+func (c *apiClient) Login(ctx context.Context) (err error) {
+	c.userID, err = c.me(ctx)
+	return err
+}
+
+// Enumerate returns the account's tweets and reposts in window, and its likes of tweets posted in
+// window if asked to. Likes can't be looked up by date, so every liked tweet is listed.
+func (c *apiClient) Enumerate(ctx context.Context, window Window) ([]Item, error) {
+	var items []Item
+	for token := ""; ; {
+		page, err := c.userTweets(ctx, c.userID, window.Since, window.Until, token)
+		if err != nil {
+			return nil, err
+		}
+		for _, tweet := range page.Data {
+			item := Item{ID: tweet.ID, Kind: ItemPost, CreatedAt: tweet.CreatedAt}
+			if source := tweet.repostOf(); source != "" {
+				item.Kind, item.Target = ItemRepost, source
+			}
+			items = append(items, item)
+		}
+		if token = page.Meta.NextToken; token == "" {
+			break
+		}
+	}
+	if !c.likes {
+		return items, nil
+	}
+
+	for token := ""; ; {
+		page, err := c.likedTweets(ctx, c.userID, token)
+		if err != nil {
+			return nil, err
+		}
+		for _, tweet := range page.Data {
+			if window.contains(tweet.CreatedAt) {
+				items = append(items, Item{ID: tweet.ID, Kind: ItemLike, Target: tweet.ID, CreatedAt: tweet.CreatedAt})
+			}
+		}
+		if token = page.Meta.NextToken; token == "" {
+			break
+		}
+	}
+	return items, nil
+}
+
+// Delete deletes a tweet, undoes a repost or removes a like
+func (c *apiClient) Delete(ctx context.Context, item Item) error {
+	switch item.Kind {
+	case ItemRepost:
+		if err := c.unrepost(ctx, c.userID, item.Target); err != nil {
+			return fmt.Errorf("could not undo repost %s: %w", item.ID, err)
+		}
+	case ItemLike:
+		if err := c.unlike(ctx, c.userID, item.Target); err != nil {
+			return fmt.Errorf("could not remove like of %s: %w", item.ID, err)
+		}
+	default:
+		if err := c.deleteTweet(ctx, item.ID); err != nil {
+			return fmt.Errorf("could not delete tweet %s: %w", item.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package internal_test
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"tweetdeleter/internal"
+	"tweetdeleter/internal/fakex"
+)
+
+// callbackAddr returns a local address that nothing is listening on, for the OAuth callback server
+func callbackAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// approve follows an authorization URL served by the fake API, which redirects straight back to the
+// callback server the way a browser approving the app would, and records the URL in authURLs. It then
+// loads the callback again, like a browser reloading the page, which must not hang.
+func approve(authURLs *[]string) func(string) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return func(authURL string) error {
+		*authURLs = append(*authURLs, authURL)
+		resp, err := client.Get(authURL)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		resp, err = client.Get(resp.Request.URL.String())
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+}
+
+// refuse fails the test if the app is asked to be authorized again
+func refuse(t *testing.T) func(string) error {
+	return func(string) error {
+		t.Error("asked to authorize the app again instead of using the saved token")
+		return errors.New("not authorized")
+	}
+}
+
+// runAPI runs a TweetDeleter in DeleteModeAPI against api over runSince to runUntil, returning the
+// logs it wrote and Run's error
+func runAPI(t *testing.T, api *httptest.Server, opts internal.APIOptions) (*observer.ObservedLogs, error) {
+	t.Helper()
+	core, logs := observer.New(zap.InfoLevel)
+	opts.ClientID, opts.BaseURL = "fake", api.URL
+	td, err := internal.NewTweetDeleter(internal.TweetDeleterOptions{
+		StartDate:  runSince,
+		EndDate:    runUntil,
+		Logger:     zap.New(core),
+		DeleteMode: internal.DeleteModeAPI,
+		API:        opts,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = td.Run()
+	return logs, err
+}
+
+// The app is authorized with PKCE, and the whole time range is paged through and deleted, including
+// reposts and likes, which the site's search doesn't show
+func TestAPIRunDeletesTweetsRepostsAndLikes(t *testing.T) {
+	tweets := fakex.GenerateTweets(250, runSince, runUntil)
+	repost := fakex.Tweet{ID: "1", RepostOf: "500", CreatedAt: runSince.Add(time.Hour)}
+	liked := fakex.Tweet{ID: "600", Text: "someone else's tweet", CreatedAt: runSince.Add(2 * time.Hour)}
+	likedBefore := fakex.Tweet{ID: "700", Text: "an older tweet", CreatedAt: runSince.Add(-time.Hour)}
+	site := fakex.NewSite(fakex.Options{
+		Username: "fake",
+		Tweets:   append(tweets, repost),
+		Likes:    []fakex.Tweet{liked, likedBefore},
+	})
+	api := httptest.NewServer(fakex.NewAPI(site, fakex.APIOptions{}))
+	defer api.Close()
+
+	var authURLs []string
+	logs, err := runAPI(t, api, internal.APIOptions{CallbackAddr: callbackAddr(t), OpenURL: approve(&authURLs), Likes: true})
+	if err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+
+	if len(authURLs) != 1 {
+		t.Fatalf("authorized %d times, want once", len(authURLs))
+	}
+	u, err := url.Parse(authURLs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := u.Query(); q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		t.Errorf("authorization URL %s doesn't send a S256 PKCE challenge", authURLs[0])
+	}
+
+	// 250 tweets take three pages of 100
+	if left := site.Tweets(); len(left) != 0 {
+		t.Errorf("%d tweets and reposts left, want 0", len(left))
+	}
+	if likes := site.Likes(); len(likes) != 1 || likes[0].ID != likedBefore.ID {
+		t.Errorf("likes left are %v, want only the like of a tweet from before the time range", likes)
+	}
+	checkReportedCount(t, logs, len(tweets)+2)
+}
+
+// A saved token is refreshed once it expires instead of authorizing the app again
+func TestAPIRefreshesSavedToken(t *testing.T) {
+	site := fakex.NewSite(fakex.Options{Username: "fake", Tweets: fakex.GenerateTweets(5, runSince, runUntil)})
+	// Tokens are refreshed a minute before they expire, so these are refreshed before every call
+	api := httptest.NewServer(fakex.NewAPI(site, fakex.APIOptions{TokenLifetime: 30 * time.Second}))
+	defer api.Close()
+	tokenFile := filepath.Join(t.TempDir(), "token.json")
+
+	var authURLs []string
+	logs, err := runAPI(t, api, internal.APIOptions{CallbackAddr: callbackAddr(t), TokenFile: tokenFile, OpenURL: approve(&authURLs)})
+	if err != nil {
+		t.Fatalf("first Run() = %v, want nil", err)
+	}
+	if len(authURLs) != 1 {
+		t.Fatalf("authorized %d times, want once", len(authURLs))
+	}
+	if n := logs.FilterMessage("could not refresh the access token. authorizing again").Len(); n != 0 {
+		t.Errorf("failed to refresh the access token %d times", n)
+	}
+	checkReportedCount(t, logs, 5)
+
+	// Refresh tokens can only be used once, so this only works if the file has the latest one
+	if _, err := runAPI(t, api, internal.APIOptions{CallbackAddr: callbackAddr(t), TokenFile: tokenFile, OpenURL: refuse(t)}); err != nil {
+		t.Fatalf("second Run() = %v, want nil", err)
+	}
+}
+
+// The token file is only written when a token is issued, not every time it is used
+func TestAPIKeepsUnexpiredTokenFile(t *testing.T) {
+	site := fakex.NewSite(fakex.Options{Username: "fake", Tweets: fakex.GenerateTweets(5, runSince, runUntil)})
+	api := httptest.NewServer(fakex.NewAPI(site, fakex.APIOptions{}))
+	defer api.Close()
+	tokenFile := filepath.Join(t.TempDir(), "token.json")
+
+	var authURLs []string
+	if _, err := runAPI(t, api, internal.APIOptions{CallbackAddr: callbackAddr(t), TokenFile: tokenFile, OpenURL: approve(&authURLs)}); err != nil {
+		t.Fatalf("first Run() = %v, want nil", err)
+	}
+	saved := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(tokenFile, saved, saved); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := runAPI(t, api, internal.APIOptions{CallbackAddr: callbackAddr(t), TokenFile: tokenFile, OpenURL: refuse(t)}); err != nil {
+		t.Fatalf("second Run() = %v, want nil", err)
+	}
+	info, err := os.Stat(tokenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(saved) {
+		t.Errorf("token file was written at %s by a run that didn't need a new token", info.ModTime())
+	}
+}